@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/auth"
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Scopes a personal access token can be granted. Routes opt in with
+// requireScope; everything else, like account settings, sessions and the
+// tokens themselves, needs an access token from logging in.
+const (
+	scopeChirpsRead   = "chirps:read"
+	scopeChirpsWrite  = "chirps:write"
+	scopeProfileRead  = "profile:read"
+	scopeProfileWrite = "profile:write"
+	scopeSocialWrite  = "social:write"
+	scopeMediaWrite   = "media:write"
+)
+
+var validScopes = map[string]bool{
+	scopeChirpsRead:   true,
+	scopeChirpsWrite:  true,
+	scopeProfileRead:  true,
+	scopeProfileWrite: true,
+	scopeSocialWrite:  true,
+	scopeMediaWrite:   true,
+}
+
+const (
+	defaultAccessTokenDays = 30
+	maxAccessTokenDays     = 365
+	maxAccessTokensPerUser = 50
+	maxAccessTokenName     = 100
+)
+
+var (
+	// errInvalidAccessToken rejects personal access tokens that are unknown,
+	// revoked or expired.
+	errInvalidAccessToken = errors.New("invalid or expired personal access token")
+	// errScopeNotAllowed rejects personal access tokens on routes no scope
+	// opens.
+	errScopeNotAllowed = errors.New("personal access tokens can't be used for this, log in instead")
+	// errMissingScope rejects personal access tokens without the route's
+	// scope.
+	errMissingScope = errors.New("personal access token lacks the scope")
+)
+
+type scopeContextKey struct{}
+
+// requireScope lets personal access tokens with scope use next. Access
+// tokens from logging in are not limited by scopes.
+func (cfg *apiConfig) requireScope(scope string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), scopeContextKey{}, scope)
+		next(w, r.WithContext(ctx))
+	})
+}
+
+// usesPersonalAccessToken reports whether r is authenticated with a personal
+// access token.
+func usesPersonalAccessToken(r *http.Request) bool {
+	token, err := auth.GetBearerToken(r.Header)
+	return err == nil && auth.IsPersonalAccessToken(token)
+}
+
+// authenticatePersonalAccessToken checks a personal access token against
+// the scope of the route and returns claims for its owner. Roles are left
+// out: tokens can't act as a moderator or admin.
+func (cfg *apiConfig) authenticatePersonalAccessToken(r *http.Request, token string) (*auth.Claims, error) {
+	scope, _ := r.Context().Value(scopeContextKey{}).(string)
+	if scope == "" {
+		return nil, errScopeNotAllowed
+	}
+
+	pat, err := cfg.dbQueries.UsePersonalAccessToken(r.Context(), auth.HashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errInvalidAccessToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(pat.Scopes, scope) {
+		return nil, fmt.Errorf("%w %s", errMissingScope, scope)
+	}
+
+	return &auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: pat.UserID.String(),
+		},
+	}, nil
+}
+
+type accessTokenResponse struct {
+	Id           uuid.UUID  `json:"id"`
+	Name         string     `json:"name"`
+	Scopes       []string   `json:"scopes"`
+	Created_at   time.Time  `json:"created_at"`
+	Expires_at   time.Time  `json:"expires_at"`
+	Last_used_at *time.Time `json:"last_used_at"`
+}
+
+func newAccessTokenResponse(token database.PersonalAccessToken) accessTokenResponse {
+	return accessTokenResponse{
+		Id:           token.ID,
+		Name:         token.Name,
+		Scopes:       token.Scopes,
+		Created_at:   token.CreatedAt,
+		Expires_at:   token.ExpiresAt,
+		Last_used_at: nullTimePtr(token.LastUsedAt),
+	}
+}
+
+// parseScopesParam validates requested scopes and returns them sorted and
+// without duplicates.
+func parseScopesParam(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	parsed := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !validScopes[scope] {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		parsed = append(parsed, scope)
+	}
+	slices.Sort(parsed)
+	return slices.Compact(parsed), nil
+}
+
+// handlerCreateAccessToken creates a personal access token for scripts and
+// bots. The token is only shown in this response.
+func (cfg *apiConfig) handlerCreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+
+	type returnVals struct {
+		accessTokenResponse
+		Token string `json:"token"`
+	}
+
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" || len([]rune(params.Name)) > maxAccessTokenName {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("name must be 1 to %d characters", maxAccessTokenName), nil)
+		return
+	}
+
+	scopes, err := parseScopesParam(params.Scopes)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	if params.ExpiresInDays == 0 {
+		params.ExpiresInDays = defaultAccessTokenDays
+	}
+	if params.ExpiresInDays < 1 || params.ExpiresInDays > maxAccessTokenDays {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("expires_in_days must be between 1 and %d", maxAccessTokenDays), nil)
+		return
+	}
+
+	count, err := cfg.dbQueries.CountUserPersonalAccessTokens(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while counting tokens", err)
+		return
+	}
+	if count >= maxAccessTokensPerUser {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("You can have at most %d tokens, revoke one first", maxAccessTokensPerUser), nil)
+		return
+	}
+
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while creating token", err)
+		return
+	}
+	pat, err := cfg.dbQueries.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:    userID,
+		Name:      params.Name,
+		TokenHash: auth.HashToken(token),
+		Scopes:    scopes,
+		ExpiresAt: time.Now().AddDate(0, 0, params.ExpiresInDays),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while creating token", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, returnVals{
+		accessTokenResponse: newAccessTokenResponse(pat),
+		Token:               token,
+	})
+}
+
+// handlerListAccessTokens lists the signed in user's personal access tokens
+// that weren't revoked, newest first.
+func (cfg *apiConfig) handlerListAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	tokens, err := cfg.dbQueries.ListUserPersonalAccessTokens(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting tokens", err)
+		return
+	}
+
+	response := make([]accessTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		response = append(response, newAccessTokenResponse(token))
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerRevokeAccessToken revokes one of the signed in user's personal
+// access tokens.
+func (cfg *apiConfig) handlerRevokeAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid token ID", err)
+		return
+	}
+
+	revoked, err := cfg.dbQueries.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while revoking token", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "Can't find token", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/auth"
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/alexanderarrr/chirpy-http-server/internal/mail"
+	"github.com/google/uuid"
+)
+
+const (
+	emailChangeTTL      = 24 * time.Hour
+	refreshTokenTTLDays = 60
+)
+
+// credentialChange holds the sign in details a PATCH /api/users/me changes.
+// Empty fields stay as they are.
+type credentialChange struct {
+	email          string
+	hashedPassword string
+}
+
+// accountUpdate carries the secrets created by updateAccount.
+type accountUpdate struct {
+	// emailToken confirms the new email. Only its hash is stored.
+	emailToken string
+	// refreshToken replaces the sessions revoked by a password change.
+	refreshToken string
+	// sessionID is the session refreshToken belongs to.
+	sessionID uuid.UUID
+}
+
+// updateAccount applies a profile update together with a credential change
+// in a single transaction. A new password revokes every refresh token of
+// the user and starts a new session for client; a new email is stored as
+// pending until it is confirmed with the returned token.
+func (cfg *apiConfig) updateAccount(ctx context.Context, update database.UpdateUserProfileParams, change credentialChange, client sessionClient) (accountUpdate, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return accountUpdate{}, err
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+	_, err = qtx.UpdateUserProfile(ctx, update)
+	if err != nil {
+		return accountUpdate{}, err
+	}
+
+	result := accountUpdate{}
+	if change.hashedPassword != "" {
+		err = qtx.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+			HashedPassword: change.hashedPassword,
+			ID:             update.ID,
+		})
+		if err != nil {
+			return accountUpdate{}, err
+		}
+
+		err = qtx.RevokeUserRefreshTokens(ctx, update.ID)
+		if err != nil {
+			return accountUpdate{}, err
+		}
+
+		result.sessionID = uuid.New()
+		result.refreshToken, err = issueRefreshToken(ctx, qtx, update.ID, result.sessionID, client)
+		if err != nil {
+			return accountUpdate{}, err
+		}
+	}
+
+	if change.email != "" {
+		result.emailToken, _ = auth.MakeRefreshToken()
+		_, err = qtx.UpsertEmailChange(ctx, database.UpsertEmailChangeParams{
+			UserID:    update.ID,
+			NewEmail:  change.email,
+			TokenHash: auth.HashToken(result.emailToken),
+			ExpiresAt: time.Now().Add(emailChangeTTL),
+		})
+		if err != nil {
+			return accountUpdate{}, err
+		}
+	}
+
+	return result, tx.Commit()
+}
+
+// sendEmailChangeConfirmation mails the token that confirms a new email
+// address to that address.
+func (cfg *apiConfig) sendEmailChangeConfirmation(ctx context.Context, email, token string) error {
+	return cfg.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Confirm your new Chirpy email",
+		Body: fmt.Sprintf("Confirm this address for your Chirpy account with the token %s\n\n"+
+			"It expires in %d hours. If you didn't ask for this, you can ignore this email.",
+			token, int(emailChangeTTL.Hours())),
+	})
+}
+
+// handlerConfirmEmailChange swaps in a pending email address. The token
+// alone identifies the account, so the link can be opened signed out.
+func (cfg *apiConfig) handlerConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+
+	type returnVals struct {
+		Id         uuid.UUID `json:"id"`
+		Updated_at time.Time `json:"updated_at"`
+		Email      string    `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	change, err := cfg.dbQueries.GetEmailChangeByToken(r.Context(), auth.HashToken(params.Token))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while confirming email", err)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+	user, err := qtx.UpdateUserEmail(r.Context(), database.UpdateUserEmailParams{
+		Email: change.NewEmail,
+		ID:    change.UserID,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email already taken", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while confirming email", err)
+		return
+	}
+
+	err = qtx.DeleteEmailChange(r.Context(), change.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while confirming email", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while confirming email", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, returnVals{
+		Id:         user.ID,
+		Updated_at: user.UpdatedAt,
+		Email:      user.Email,
+	})
+}
@@ -1,20 +1,175 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"sync/atomic"
+	"time"
 
+	"github.com/alexanderarrr/chirpy-http-server/internal/auth"
 	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/alexanderarrr/chirpy-http-server/internal/mail"
+	"github.com/alexanderarrr/chirpy-http-server/internal/media"
+	"github.com/alexanderarrr/chirpy-http-server/internal/moderation"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
+	db             *sql.DB
 	dbQueries      database.Queries
 	platform       string
-	tokenSecret    string
 	polkaKey       string
+	// keys signs access tokens and verifies them during key rotation.
+	keys *auth.Keyring
+
+	moderator           moderation.Chain
+	moderationWords     *moderation.WordList
+	moderationWordsFile string
+
+	blobs  media.BlobStore
+	mailer mail.Mailer
+	// publicURL is where clients reach the server, used for links in emails.
+	publicURL            string
+	requireVerifiedEmail bool
+	// deletionGracePeriod is how long a deleted account can still be
+	// restored by logging in before it is purged.
+	deletionGracePeriod time.Duration
+}
+
+// authenticateClaims validates the request's access token or personal
+// access token and checks that the account it was issued to is neither
+// suspended, banned nor waiting to be deleted, and that the session it
+// belongs to wasn't signed out.
+func (cfg *apiConfig) authenticateClaims(r *http.Request) (*auth.Claims, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims *auth.Claims
+	if auth.IsPersonalAccessToken(token) {
+		claims, err = cfg.authenticatePersonalAccessToken(r, token)
+	} else {
+		claims, err = cfg.keys.ParseJWT(token)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		return nil, err
+	}
+
+	standing, err := cfg.dbQueries.GetUserStanding(r.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkStanding(standing.Banned, standing.SuspendedUntil)
+	if err != nil {
+		return nil, err
+	}
+	if standing.DeletionRequestedAt.Valid {
+		return nil, errDeletionPending
+	}
+
+	if sessionID := claims.Session(); sessionID != uuid.Nil {
+		active, err := cfg.dbQueries.IsSessionActive(r.Context(), sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return nil, errSessionRevoked
+		}
+	}
+	return claims, nil
+}
+
+// authenticate returns the ID of the user owning the request's access token.
+func (cfg *apiConfig) authenticate(r *http.Request) (uuid.UUID, error) {
+	claims, err := cfg.authenticateClaims(r)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return claims.UserID()
+}
+
+// viewer is who a chirp response is rendered for.
+type viewer struct {
+	// ID is the signed in user, if any.
+	ID uuid.NullUUID
+	// Moderator is set for moderators and admins, who see hidden chirps.
+	Moderator bool
+}
+
+// userViewer renders chirps for a signed in user without moderator powers.
+func userViewer(userID uuid.UUID) viewer {
+	return viewer{ID: uuid.NullUUID{UUID: userID, Valid: true}}
+}
+
+// authenticateViewer is authenticate for endpoints that render chirps.
+func (cfg *apiConfig) authenticateViewer(r *http.Request) (viewer, error) {
+	claims, err := cfg.authenticateClaims(r)
+	if err != nil {
+		return viewer{}, err
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		return viewer{}, err
+	}
+	return viewer{
+		ID:        uuid.NullUUID{UUID: userID, Valid: true},
+		Moderator: hasRole(claims.Roles, roleModerator),
+	}, nil
+}
+
+// viewer is like authenticateViewer for endpoints that also serve anonymous
+// requests. A missing or invalid token yields an anonymous viewer.
+func (cfg *apiConfig) viewer(r *http.Request) viewer {
+	v, err := cfg.authenticateViewer(r)
+	if err != nil {
+		return viewer{}
+	}
+	return v
+}
+
+// isUniqueViolation reports whether err came from a unique constraint.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isUniqueViolationOf is isUniqueViolation for the named constraint.
+func isUniqueViolationOf(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+// appFileServer serves the web app from root: index.html and the files
+// under assets/. Nothing else in root is reachable, so keys, mail or
+// configuration kept next to the app can't be downloaded.
+func appFileServer(root string) http.Handler {
+	assets := http.FileServer(http.Dir(root))
+	index := filepath.Join(root, "index.html")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		switch {
+		case name == "/" || name == "/index.html":
+			http.ServeFile(w, r, index)
+		case strings.HasPrefix(name, "/assets/") && !strings.HasSuffix(r.URL.Path, "/"):
+			assets.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -37,10 +192,15 @@ func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(metricsOutput))
 }
 
-func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
+// isAdminPlatform reports whether destructive development endpoints like
+// reset are enabled on this deployment. They additionally require the admin
+// role.
+func (cfg *apiConfig) isAdminPlatform() bool {
+	return cfg.platform == os.Getenv("EXPECTED_PLATFORM")
+}
 
-	expectedPlatform := os.Getenv("EXPECTED_PLATFORM")
-	if cfg.platform != expectedPlatform {
+func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
+	if !cfg.isAdminPlatform() {
 		w.WriteHeader(http.StatusForbidden)
 		return
 	}
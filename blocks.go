@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const blockedMessage = "You have been blocked by this user"
+
+type blockedUserResponse struct {
+	User_id    uuid.UUID `json:"user_id"`
+	Created_at time.Time `json:"created_at"`
+}
+
+// isBlockedBy reports whether blockerID has blocked userID.
+func (cfg *apiConfig) isBlockedBy(ctx context.Context, blockerID, userID uuid.UUID) (bool, error) {
+	return cfg.dbQueries.IsBlocked(ctx, database.IsBlockedParams{
+		BlockerID: blockerID,
+		BlockedID: userID,
+	})
+}
+
+// parseTargetUser authenticates the request and returns the signed in user
+// together with the user named in the path, who must exist and be someone
+// else.
+func (cfg *apiConfig) parseTargetUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if targetID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can not do this to yourself", nil)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	_, err = cfg.dbQueries.GetUserByID(r.Context(), targetID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Can't find user", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, targetID, true
+}
+
+// handlerBlockUser blocks a user. Any follows between the two users are
+// removed, since the blocked user may no longer follow the blocker.
+func (cfg *apiConfig) handlerBlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.parseTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while blocking user", err)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+	err = qtx.BlockUser(r.Context(), database.BlockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err == nil {
+		err = qtx.DeleteFollowsBetween(r.Context(), database.DeleteFollowsBetweenParams{
+			UserA: userID,
+			UserB: targetID,
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while blocking user", err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerUnblockUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	blockedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	err = cfg.dbQueries.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userID,
+		BlockedID: blockedID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while unblocking user", err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// handlerMuteUser hides a user's chirps from the signed in user's lists and
+// timeline. Unlike a block, the muted user isn't restricted in any way.
+func (cfg *apiConfig) handlerMuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.parseTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.dbQueries.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while muting user", err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerUnmuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	mutedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	err = cfg.dbQueries.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: userID,
+		MutedID: mutedID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while unmuting user", err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetMyBlocks(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	rows, err := cfg.dbQueries.ListBlockedUsers(r.Context(), database.ListBlockedUsersParams{
+		UserID:          userID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageLimit:       page.queryLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting blocked users", err)
+		return
+	}
+
+	rows, nextCursor := trimPage(rows, page.Limit, func(row database.ListBlockedUsersRow) pageCursor {
+		return pageCursor{CreatedAt: row.CreatedAt, ID: row.UserID}
+	})
+
+	response := make([]blockedUserResponse, 0, len(rows))
+	for _, row := range rows {
+		response = append(response, blockedUserResponse{
+			User_id:    row.UserID,
+			Created_at: row.CreatedAt,
+		})
+	}
+
+	setNextPageHeaders(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerGetMyMutes(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	rows, err := cfg.dbQueries.ListMutedUsers(r.Context(), database.ListMutedUsersParams{
+		UserID:          userID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageLimit:       page.queryLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting muted users", err)
+		return
+	}
+
+	rows, nextCursor := trimPage(rows, page.Limit, func(row database.ListMutedUsersRow) pageCursor {
+		return pageCursor{CreatedAt: row.CreatedAt, ID: row.UserID}
+	})
+
+	response := make([]blockedUserResponse, 0, len(rows))
+	for _, row := range rows {
+		response = append(response, blockedUserResponse{
+			User_id:    row.UserID,
+			Created_at: row.CreatedAt,
+		})
+	}
+
+	setNextPageHeaders(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, response)
+}
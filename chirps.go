@@ -1,38 +1,205 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
-	"strings"
 	"time"
 
-	"github.com/alexanderarrr/chirpy-http-server/internal/auth"
 	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/alexanderarrr/chirpy-http-server/internal/moderation"
 	"github.com/google/uuid"
 )
 
-func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Body string `json:"body"`
+type chirpResponse struct {
+	Id         uuid.UUID         `json:"id"`
+	Created_at time.Time         `json:"created_at"`
+	Updated_at time.Time         `json:"updated_at"`
+	Body       string            `json:"body"`
+	User_id    uuid.UUID         `json:"user_id"`
+	Author     *authorResponse   `json:"author,omitempty"`
+	InReplyTo  *uuid.UUID        `json:"in_reply_to,omitempty"`
+	ThreadID   *uuid.UUID        `json:"thread_id,omitempty"`
+	Deleted    bool              `json:"deleted,omitempty"`
+	Hidden     bool              `json:"hidden,omitempty"`
+	Edited     bool              `json:"edited"`
+	EditCount  int32             `json:"edit_count"`
+	LikeCount  int64             `json:"like_count"`
+	LikedByMe  *bool             `json:"liked_by_me,omitempty"`
+	Mentions   []mentionResponse `json:"mentions"`
+	Media      []mediaResponse   `json:"media"`
+
+	// Rechirps embed the original chirp and name the user who reposted it,
+	// quotes embed the chirp they comment on.
+	RechirpOf   *chirpResponse `json:"rechirp_of,omitempty"`
+	RechirpedBy *uuid.UUID     `json:"rechirped_by,omitempty"`
+	QuoteOf     *chirpResponse `json:"quote_of,omitempty"`
+
+	rechirpOfID uuid.NullUUID
+	quoteOfID   uuid.NullUUID
+}
+
+func newChirpResponse(chirp database.Chirp) chirpResponse {
+	return chirpResponse{
+		Id:         chirp.ID,
+		Created_at: chirp.CreatedAt,
+		Updated_at: chirp.UpdatedAt,
+		Body:       chirp.Body,
+		User_id:    chirp.UserID,
+		InReplyTo:  nullUUIDPtr(chirp.InReplyTo),
+		ThreadID:   nullUUIDPtr(chirp.ThreadID),
+		Deleted:    chirp.DeletedAt.Valid,
+		Hidden:     chirp.HiddenAt.Valid,
+		Edited:     chirp.EditCount > 0,
+		EditCount:  chirp.EditCount,
+
+		rechirpOfID: chirp.RechirpOf,
+		quoteOfID:   chirp.QuoteOf,
+	}
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+func newChirpResponses(chirps []database.Chirp) []chirpResponse {
+	response := make([]chirpResponse, 0, len(chirps))
+	for _, chirp := range chirps {
+		response = append(response, newChirpResponse(chirp))
+	}
+	return response
+}
+
+// decorateChirps fills in everything a chirp response carries beyond its
+// own row, as seen by v.
+func (cfg *apiConfig) decorateChirps(ctx context.Context, chirps []chirpResponse, v viewer) error {
+	err := cfg.attachReferences(ctx, chirps, v)
+	if err != nil {
+		return err
+	}
+	err = cfg.attachAuthors(ctx, chirps)
+	if err != nil {
+		return err
+	}
+	err = cfg.attachMentions(ctx, chirps)
+	if err != nil {
+		return err
+	}
+	err = cfg.attachLikes(ctx, chirps, v.ID)
+	if err != nil {
+		return err
+	}
+	err = cfg.attachMedia(ctx, chirps)
+	if err != nil {
+		return err
+	}
+	redactHidden(chirps, v)
+	return nil
+}
+
+// redactHidden blanks chirps hidden by a moderator for everyone but their
+// author and moderators. Listings already leave them out; this covers
+// threads and embeds, which keep them in place so the structure around
+// them stays intact.
+func redactHidden(chirps []chirpResponse, v viewer) {
+	for i := range chirps {
+		if !chirps[i].Hidden || v.Moderator || (v.ID.Valid && v.ID.UUID == chirps[i].User_id) {
+			continue
+		}
+		chirps[i].Body = ""
+		chirps[i].Mentions = []mentionResponse{}
+		chirps[i].Media = []mediaResponse{}
+	}
+}
+
+// attachReferences embeds the original chirp of every rechirp and quote.
+// Originals that were deleted are embedded as tombstones.
+func (cfg *apiConfig) attachReferences(ctx context.Context, chirps []chirpResponse, v viewer) error {
+	var ids []uuid.UUID
+	for _, chirp := range chirps {
+		if chirp.rechirpOfID.Valid {
+			ids = append(ids, chirp.rechirpOfID.UUID)
+		}
+		if chirp.quoteOfID.Valid {
+			ids = append(ids, chirp.quoteOfID.UUID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
 	}
 
-	type returnVals struct {
-		Id         uuid.UUID `json:"id"`
-		Created_at time.Time `json:"created_at"`
-		Updated_at time.Time `json:"updated_at"`
-		Body       string    `json:"body"`
-		User_id    uuid.UUID `json:"user_id"`
+	originals, err := cfg.dbQueries.GetChirpsByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	embedded := newChirpResponses(originals)
+	err = cfg.attachAuthors(ctx, embedded)
+	if err != nil {
+		return err
+	}
+	err = cfg.attachMentions(ctx, embedded)
+	if err != nil {
+		return err
+	}
+	err = cfg.attachLikes(ctx, embedded, v.ID)
+	if err != nil {
+		return err
+	}
+	err = cfg.attachMedia(ctx, embedded)
+	if err != nil {
+		return err
+	}
+	redactHidden(embedded, v)
+
+	byID := make(map[uuid.UUID]chirpResponse, len(embedded))
+	for _, original := range embedded {
+		byID[original.Id] = original
+	}
+
+	for i := range chirps {
+		if original, ok := byID[chirps[i].rechirpOfID.UUID]; ok && chirps[i].rechirpOfID.Valid {
+			rechirpedBy := chirps[i].User_id
+			chirps[i].RechirpOf = &original
+			chirps[i].RechirpedBy = &rechirpedBy
+		}
+		if quoted, ok := byID[chirps[i].quoteOfID.UUID]; ok && chirps[i].quoteOfID.Valid {
+			chirps[i].QuoteOf = &quoted
+		}
+	}
+	return nil
+}
+
+func chirpCursor(chirp database.Chirp) pageCursor {
+	return pageCursor{CreatedAt: chirp.CreatedAt, ID: chirp.ID}
+}
+
+func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body      string      `json:"body"`
+		InReplyTo *uuid.UUID  `json:"in_reply_to"`
+		RechirpOf *uuid.UUID  `json:"rechirp_of"`
+		QuoteOf   *uuid.UUID  `json:"quote_of"`
+		MediaIDs  []uuid.UUID `json:"media_ids"`
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	userID, err := cfg.authenticate(r)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Missing/invalid header", err)
+		respondWithAuthError(w, err)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.tokenSecret)
+	err = cfg.checkCanPost(r.Context(), userID)
+	if errors.Is(err, errEmailNotVerified) {
+		respondWithError(w, http.StatusForbidden, "Verify your email address before posting", err)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Missing/invalid header", err)
+		respondWithError(w, http.StatusInternalServerError, "Error while checking account", err)
 		return
 	}
 
@@ -44,73 +211,230 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	const maxChirpLength = 140
-	if len(params.Body) > maxChirpLength {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
+	verdict, err := cfg.moderateChirpBody(params.Body)
+	if errors.Is(err, errChirpRejected) {
+		respondWithError(w, http.StatusBadRequest, "Chirp was rejected by moderation", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long", err)
 		return
 	}
-
-	cleanedBody := cleanChirp(params.Body)
 
 	chirpParams := database.CreateChirpParams{
-		Body:   cleanedBody,
+		Body:   verdict.Body,
 		UserID: userID,
 	}
-	chirp, err := cfg.dbQueries.CreateChirp(r.Context(), chirpParams)
+	viewerID := uuid.NullUUID{UUID: userID, Valid: true}
+
+	if params.InReplyTo != nil {
+		parent, err := cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+			ID:       *params.InReplyTo,
+			ViewerID: viewerID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "The chirp you are replying to does not exist", err)
+			return
+		}
+		blocked, err := cfg.isBlockedBy(r.Context(), parent.UserID, userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error while creating chirp", err)
+			return
+		}
+		if blocked {
+			respondWithError(w, http.StatusForbidden, blockedMessage, nil)
+			return
+		}
+		chirpParams.InReplyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		// Replies share the thread of their parent; a root chirp starts its own.
+		if parent.ThreadID.Valid {
+			chirpParams.ThreadID = parent.ThreadID
+		} else {
+			chirpParams.ThreadID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		}
+	}
+
+	if params.RechirpOf != nil {
+		if params.QuoteOf != nil || params.InReplyTo != nil || params.Body != "" || len(params.MediaIDs) > 0 {
+			respondWithError(w, http.StatusBadRequest, "A rechirp can not have a body, media, quote or reply", nil)
+			return
+		}
+		original, err := cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+			ID:       *params.RechirpOf,
+			ViewerID: viewerID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "The chirp you are rechirping does not exist", err)
+			return
+		}
+		// Rechirping a rechirp reposts the chirp it points at.
+		if original.RechirpOf.Valid {
+			chirpParams.RechirpOf = original.RechirpOf
+		} else {
+			chirpParams.RechirpOf = uuid.NullUUID{UUID: original.ID, Valid: true}
+		}
+	}
+
+	if params.QuoteOf != nil {
+		if params.Body == "" {
+			respondWithError(w, http.StatusBadRequest, "A quote chirp needs a body", nil)
+			return
+		}
+		quoted, err := cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+			ID:       *params.QuoteOf,
+			ViewerID: viewerID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "The chirp you are quoting does not exist", err)
+			return
+		}
+		if quoted.RechirpOf.Valid {
+			chirpParams.QuoteOf = quoted.RechirpOf
+		} else {
+			chirpParams.QuoteOf = uuid.NullUUID{UUID: quoted.ID, Valid: true}
+		}
+	}
+
+	err = parseMediaIDs(params.MediaIDs)
 	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if len(params.MediaIDs) > 0 {
+		_, err = cfg.getOwnMedia(r.Context(), params.MediaIDs, userID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "You can only attach media you uploaded", err)
+			return
+		}
+	}
+
+	chirp, err := cfg.createChirp(r.Context(), chirpParams, params.MediaIDs, verdict)
+	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "You already rechirped this chirp", err)
+			return
+		}
 		respondWithError(w, http.StatusBadRequest, "Error while creating chirp", err)
 		return
 	}
 
-	response := returnVals{
-		Id:         chirp.ID,
-		Created_at: chirp.CreatedAt,
-		Updated_at: chirp.UpdatedAt,
-		Body:       chirp.Body,
-		User_id:    chirp.UserID,
+	response := []chirpResponse{newChirpResponse(chirp)}
+	err = cfg.decorateChirps(r.Context(), response, userViewer(userID))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting referenced chirps", err)
+		return
 	}
-	respondWithJSON(w, http.StatusCreated, response)
+
+	respondWithJSON(w, http.StatusCreated, response[0])
 }
 
-func cleanChirp(body string) string {
-	profanity := []string{"kerfuffle", "sharbert", "fornax"}
-	splitString := strings.Split(body, " ")
-	for i, ch := range splitString {
-		for _, prof := range profanity {
-			if strings.ToLower(ch) == prof {
-				splitString[i] = "****"
-			}
+// createChirp stores a chirp together with its media and the hashtags and
+// mentions found in its body, and queues it for review if moderation
+// flagged it.
+func (cfg *apiConfig) createChirp(ctx context.Context, params database.CreateChirpParams, mediaIDs []uuid.UUID, verdict moderation.Verdict) (database.Chirp, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+	chirp, err := qtx.CreateChirp(ctx, params)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	for i, mediaID := range mediaIDs {
+		err = qtx.AttachChirpMedia(ctx, database.AttachChirpMediaParams{
+			ChirpID:  chirp.ID,
+			MediaID:  mediaID,
+			Position: int32(i),
+		})
+		if err != nil {
+			return database.Chirp{}, err
 		}
 	}
-	return strings.Join(splitString, " ")
+
+	err = indexChirpBody(ctx, qtx, chirp)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	err = flagChirp(ctx, qtx, chirp.ID, verdict)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	return chirp, tx.Commit()
+}
+
+// indexChirpBody stores the hashtags and mentions found in a chirp body.
+func indexChirpBody(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	err := saveChirpTags(ctx, q, chirp.ID, chirp.Body)
+	if err != nil {
+		return err
+	}
+	return saveChirpMentions(ctx, q, chirp)
 }
 
 func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
-	type returnVals struct {
-		Id         uuid.UUID `json:"id"`
-		Created_at time.Time `json:"created_at"`
-		Updated_at time.Time `json:"updated_at"`
-		Body       string    `json:"body"`
-		User_id    uuid.UUID `json:"user_id"`
+	query := r.URL.Query()
+	page, err := parsePageParams(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
 	}
 
-	chirps, err := cfg.dbQueries.GetChirps(r.Context())
+	authorID := uuid.NullUUID{}
+	if authorIDString := query.Get("author_id"); authorIDString != "" {
+		authorID.UUID, err = uuid.Parse(authorIDString)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author ID", err)
+			return
+		}
+		authorID.Valid = true
+	}
+
+	v := cfg.viewer(r)
+	var chirps []database.Chirp
+	switch query.Get("sort") {
+	case "", "asc":
+		chirps, err = cfg.dbQueries.ListChirpsAsc(r.Context(), database.ListChirpsAscParams{
+			AuthorID:          authorID,
+			ViewerID:          v.ID,
+			ViewerIsModerator: v.Moderator,
+			CursorCreatedAt:   page.cursorCreatedAt(),
+			CursorID:          page.cursorID(),
+			PageLimit:         page.queryLimit(),
+		})
+	case "desc":
+		chirps, err = cfg.dbQueries.ListChirpsDesc(r.Context(), database.ListChirpsDescParams{
+			AuthorID:          authorID,
+			ViewerID:          v.ID,
+			ViewerIsModerator: v.Moderator,
+			CursorCreatedAt:   page.cursorCreatedAt(),
+			CursorID:          page.cursorID(),
+			PageLimit:         page.queryLimit(),
+		})
+	default:
+		respondWithError(w, http.StatusBadRequest, "sort must be either asc or desc", nil)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error while getting chirps", err)
 		return
 	}
 
-	var response []returnVals
-	for _, chirp := range chirps {
-		response = append(response, returnVals{
-			Id:         chirp.ID,
-			Created_at: chirp.CreatedAt,
-			Updated_at: chirp.UpdatedAt,
-			Body:       chirp.Body,
-			User_id:    chirp.UserID,
-		})
+	chirps, nextCursor := trimPage(chirps, page.Limit, chirpCursor)
+
+	response := newChirpResponses(chirps)
+	err = cfg.decorateChirps(r.Context(), response, v)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting chirp details", err)
+		return
 	}
 
+	setNextPageHeaders(w, r, nextCursor)
 	respondWithJSON(w, http.StatusOK, response)
 	return
 }
@@ -123,40 +447,48 @@ func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chirp, err := cfg.dbQueries.GetChirp(r.Context(), chirpID)
+	v := cfg.viewer(r)
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+		ID:                chirpID,
+		ViewerID:          v.ID,
+		ViewerIsModerator: v.Moderator,
+	})
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Could not get chirp", err)
 		return
 	}
 
-	type returnVals struct {
-		Id         uuid.UUID `json:"id"`
-		Created_at time.Time `json:"created_at"`
-		Updated_at time.Time `json:"updated_at"`
-		Body       string    `json:"body"`
-		User_id    uuid.UUID `json:"user_id"`
-	}
-	response := returnVals{
-		Id:         chirp.ID,
-		Created_at: chirp.CreatedAt,
-		Updated_at: chirp.UpdatedAt,
-		Body:       chirp.Body,
-		User_id:    chirp.UserID,
+	response := []chirpResponse{newChirpResponse(chirp)}
+	err = cfg.decorateChirps(r.Context(), response, v)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting chirp details", err)
+		return
 	}
 
-	respondWithJSON(w, http.StatusOK, response)
+	respondWithJSON(w, http.StatusOK, response[0])
 }
 
-func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
-	accessTokenString, err := auth.GetBearerToken(r.Header)
+var errNotChirpOwner = errors.New("chirp belongs to another user")
+
+// getOwnChirp loads a chirp and makes sure it was written by userID.
+func (cfg *apiConfig) getOwnChirp(ctx context.Context, chirpID, userID uuid.UUID) (database.Chirp, error) {
+	chirp, err := cfg.dbQueries.GetChirp(ctx, database.GetChirpParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Missing access token in header", err)
-		return
+		return database.Chirp{}, err
 	}
+	if chirp.UserID != userID {
+		return database.Chirp{}, errNotChirpOwner
+	}
+	return chirp, nil
+}
 
-	userID, err := auth.ValidateJWT(accessTokenString, cfg.tokenSecret)
+func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
 	if err != nil {
-		respondWithError(w, http.StatusForbidden, "FORBIDDEN! Wrong access token", err)
+		respondWithAuthError(w, err)
 		return
 	}
 
@@ -166,21 +498,35 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	chirp, err := cfg.dbQueries.GetChirp(r.Context(), chirpID)
+	chirp, err := cfg.getOwnChirp(r.Context(), chirpID, userID)
+	if errors.Is(err, errNotChirpOwner) {
+		respondWithError(w, http.StatusForbidden, "You can only delete your own chirps", err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Specified chirp does not exist", err)
 		return
 	}
 
-	if chirp.UserID != userID {
-		respondWithError(w, http.StatusForbidden, "You can only delete your own chirps", err)
+	dependents, err := cfg.dbQueries.CountChirpDependents(r.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while deleting chirp", err)
 		return
 	}
 
-	err = cfg.dbQueries.DeleteChirp(r.Context(), database.DeleteChirpParams{
-		ID:     chirp.ID,
-		UserID: userID,
-	})
+	// Chirps with replies or quotes are kept as tombstones so threads and
+	// quotes keep pointing at something.
+	if dependents > 0 {
+		err = cfg.dbQueries.TombstoneChirp(r.Context(), database.TombstoneChirpParams{
+			ID:     chirp.ID,
+			UserID: userID,
+		})
+	} else {
+		err = cfg.dbQueries.DeleteChirp(r.Context(), database.DeleteChirpParams{
+			ID:     chirp.ID,
+			UserID: userID,
+		})
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error while deleting chirp", err)
 		return
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+)
+
+// runCommand runs a maintenance command instead of starting the server.
+// The first admin of a fresh deployment is created with
+//
+//	chirpy grant-role <email> admin
+func runCommand(ctx context.Context, db *sql.DB, q *database.Queries, args []string) error {
+	switch args[0] {
+	case "grant-role", "revoke-role":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: %s <email> <role>", args[0])
+		}
+		email, role := args[1], args[2]
+		if !validRoles[role] {
+			return errors.New("role must be either admin or moderator")
+		}
+
+		user, err := q.GetUser(ctx, email)
+		if err != nil {
+			return fmt.Errorf("couldn't find user %s: %w", email, err)
+		}
+
+		if args[0] == "grant-role" {
+			_, err = q.GrantUserRole(ctx, database.GrantUserRoleParams{Role: role, ID: user.ID})
+		} else {
+			_, err = revokeUserRole(ctx, db, q, user.ID, role)
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
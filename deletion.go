@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/auth"
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const purgeBatchSize = 100
+
+// handlerDeleteMe schedules the signed in user's account for deletion. The
+// password has to be confirmed, every session is signed out, and the
+// account is purged once the grace period has passed.
+func (cfg *apiConfig) handlerDeleteMe(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+
+	type returnVals struct {
+		PurgeAt time.Time `json:"purge_at"`
+	}
+
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting user", err)
+		return
+	}
+	err = auth.CheckPasswordHash(user.HashedPassword, params.Password)
+	if err != nil {
+		respondWithError(w, http.StatusForbidden, "password is incorrect", err)
+		return
+	}
+
+	err = cfg.requestDeletion(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while deleting account", err)
+		return
+	}
+
+	if cfg.deletionGracePeriod == 0 {
+		_, err = cfg.purgeUser(r.Context(), userID, time.Now())
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error while deleting account", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, returnVals{
+		PurgeAt: time.Now().Add(cfg.deletionGracePeriod),
+	})
+}
+
+func (cfg *apiConfig) requestDeletion(ctx context.Context, userID uuid.UUID) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+	err = qtx.RequestUserDeletion(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = qtx.RevokeUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// purgeUser deletes an account whose deletion was requested before cutoff,
+// along with its uploads. Everything else the user owns goes with the row
+// through ON DELETE CASCADE. It reports false if the account was restored
+// in the meantime.
+func (cfg *apiConfig) purgeUser(ctx context.Context, userID uuid.UUID, cutoff time.Time) (bool, error) {
+	files, err := cfg.dbQueries.ListUserMediaFiles(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := cfg.dbQueries.DeleteUserDueForDeletion(ctx, database.DeleteUserDueForDeletionParams{
+		ID:                  userID,
+		DeletionRequestedAt: sql.NullTime{Time: cutoff, Valid: true},
+	})
+	if err != nil || deleted == 0 {
+		return false, err
+	}
+
+	// The rows are gone already, so a blob that fails to delete is only
+	// an orphaned file.
+	for _, file := range files {
+		for _, key := range []string{file.BlobKey, file.ThumbnailKey} {
+			err = cfg.blobs.Delete(ctx, key)
+			if err != nil {
+				log.Printf("Error while deleting blob %s of purged user %s: %v", key, userID, err)
+			}
+		}
+	}
+	return true, nil
+}
+
+// purgeDeletedAccounts purges every account whose grace period is over and
+// returns how many were deleted.
+func (cfg *apiConfig) purgeDeletedAccounts(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-cfg.deletionGracePeriod)
+	purged := 0
+	for {
+		ids, err := cfg.dbQueries.ListUsersDueForDeletion(ctx, database.ListUsersDueForDeletionParams{
+			DeletionRequestedAt: sql.NullTime{Time: cutoff, Valid: true},
+			Limit:               purgeBatchSize,
+		})
+		if err != nil {
+			return purged, err
+		}
+
+		for _, id := range ids {
+			deleted, err := cfg.purgeUser(ctx, id, cutoff)
+			if err != nil {
+				return purged, err
+			}
+			if deleted {
+				purged++
+			}
+		}
+
+		if len(ids) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// runDeletionPurger calls purgeDeletedAccounts every interval until ctx is
+// done.
+func (cfg *apiConfig) runDeletionPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := cfg.purgeDeletedAccounts(ctx)
+		if err != nil {
+			log.Printf("Error while purging deleted accounts: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted accounts", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
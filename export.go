@@ -0,0 +1,228 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+type exportProfile struct {
+	Id            uuid.UUID `json:"id"`
+	Created_at    time.Time `json:"created_at"`
+	Updated_at    time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Handle        string    `json:"handle,omitempty"`
+	DisplayName   string    `json:"display_name"`
+	Bio           string    `json:"bio"`
+	Location      string    `json:"location"`
+	Website       string    `json:"website"`
+	AvatarURL     string    `json:"avatar_url"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Roles         []string  `json:"roles"`
+}
+
+// exportSession describes a refresh token without the token itself.
+type exportSession struct {
+	SessionID    uuid.UUID  `json:"session_id"`
+	DeviceName   string     `json:"device_name"`
+	UserAgent    string     `json:"user_agent"`
+	IpAddress    string     `json:"ip_address"`
+	Created_at   time.Time  `json:"created_at"`
+	Last_used_at time.Time  `json:"last_used_at"`
+	Expires_at   time.Time  `json:"expires_at"`
+	Rotated_at   *time.Time `json:"rotated_at,omitempty"`
+	Revoked_at   *time.Time `json:"revoked_at,omitempty"`
+}
+
+type userExport struct {
+	Exported_at time.Time       `json:"exported_at"`
+	Profile     exportProfile   `json:"profile"`
+	Chirps      []chirpResponse `json:"chirps"`
+	Media       []mediaResponse `json:"media"`
+	Sessions    []exportSession `json:"sessions"`
+
+	files []database.MediaFile
+}
+
+// collectExport gathers everything the user's data export contains.
+func (cfg *apiConfig) collectExport(ctx context.Context, userID uuid.UUID) (userExport, error) {
+	user, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return userExport{}, err
+	}
+
+	chirps, err := cfg.dbQueries.ListUserChirps(ctx, userID)
+	if err != nil {
+		return userExport{}, err
+	}
+	responses := newChirpResponses(chirps)
+	err = cfg.attachMentions(ctx, responses)
+	if err != nil {
+		return userExport{}, err
+	}
+	err = cfg.attachMedia(ctx, responses)
+	if err != nil {
+		return userExport{}, err
+	}
+
+	files, err := cfg.dbQueries.ListUserMediaFiles(ctx, userID)
+	if err != nil {
+		return userExport{}, err
+	}
+	media := make([]mediaResponse, 0, len(files))
+	for _, file := range files {
+		media = append(media, cfg.newMediaResponse(file))
+	}
+
+	tokens, err := cfg.dbQueries.ListUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return userExport{}, err
+	}
+	sessions := make([]exportSession, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, exportSession{
+			SessionID:    token.FamilyID,
+			DeviceName:   token.DeviceName,
+			UserAgent:    token.UserAgent,
+			IpAddress:    token.IpAddress,
+			Created_at:   token.CreatedAt,
+			Last_used_at: token.LastUsedAt,
+			Expires_at:   token.ExpiresAt,
+			Rotated_at:   nullTimePtr(token.RotatedAt),
+			Revoked_at:   nullTimePtr(token.RevokedAt),
+		})
+	}
+
+	return userExport{
+		Exported_at: time.Now().UTC(),
+		Profile: exportProfile{
+			Id:            user.ID,
+			Created_at:    user.CreatedAt,
+			Updated_at:    user.UpdatedAt,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerifiedAt.Valid,
+			Handle:        user.Handle.String,
+			DisplayName:   user.DisplayName,
+			Bio:           user.Bio,
+			Location:      user.Location,
+			Website:       user.Website,
+			AvatarURL:     user.AvatarUrl,
+			IsChirpyRed:   user.IsChirpyRed.Bool,
+			Roles:         user.Roles,
+		},
+		Chirps:   responses,
+		Media:    media,
+		Sessions: sessions,
+		files:    files,
+	}, nil
+}
+
+// handlerExportMe streams the signed in user's data as a JSON document, or
+// with ?format=zip as an archive that also holds the uploaded files.
+func (cfg *apiConfig) handlerExportMe(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "zip" {
+		respondWithError(w, http.StatusBadRequest, "format must be json or zip", nil)
+		return
+	}
+
+	export, err := cfg.collectExport(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while exporting data", err)
+		return
+	}
+
+	filename := fmt.Sprintf("chirpy-export-%s", export.Exported_at.Format("20060102"))
+	if format != "zip" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		err = json.NewEncoder(w).Encode(export)
+		if err != nil {
+			log.Printf("Error while streaming export: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, filename))
+	err = cfg.writeExportZip(r.Context(), w, export)
+	if err != nil {
+		// The status line is sent already; the client sees a broken archive.
+		log.Printf("Error while streaming export: %v", err)
+	}
+}
+
+func (cfg *apiConfig) writeExportZip(ctx context.Context, w io.Writer, export userExport) error {
+	archive := zip.NewWriter(w)
+
+	documents := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", export.Profile},
+		{"chirps.json", export.Chirps},
+		{"media.json", export.Media},
+		{"sessions.json", export.Sessions},
+	}
+	for _, document := range documents {
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     document.name,
+			Method:   zip.Deflate,
+			Modified: export.Exported_at,
+		})
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(document.data)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, file := range export.files {
+		err := cfg.copyBlobToZip(ctx, archive, file.BlobKey, file.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func (cfg *apiConfig) copyBlobToZip(ctx context.Context, archive *zip.Writer, key string, modified time.Time) error {
+	blob, err := cfg.blobs.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", key, err)
+	}
+	defer blob.Close()
+
+	// Images are compressed already.
+	file, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     path.Join("media", path.Base(key)),
+		Method:   zip.Store,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, blob)
+	return err
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+type followResponse struct {
+	User_id     uuid.UUID `json:"user_id"`
+	Followed_at time.Time `json:"followed_at"`
+}
+
+func (cfg *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	if followeeID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can not follow yourself", nil)
+		return
+	}
+
+	_, err = cfg.dbQueries.GetUserByID(r.Context(), followeeID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Can't find user", err)
+		return
+	}
+
+	blocked, err := cfg.isBlockedBy(r.Context(), followeeID, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while following user", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, blockedMessage, nil)
+		return
+	}
+
+	err = cfg.dbQueries.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while following user", err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	err = cfg.dbQueries.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while unfollowing user", err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetFollowers(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	followers, err := cfg.dbQueries.ListFollowers(r.Context(), database.ListFollowersParams{
+		UserID:          userID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageLimit:       page.queryLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting followers", err)
+		return
+	}
+
+	followers, nextCursor := trimPage(followers, page.Limit, func(f database.ListFollowersRow) pageCursor {
+		return pageCursor{CreatedAt: f.FollowedAt, ID: f.UserID}
+	})
+
+	response := []followResponse{}
+	for _, f := range followers {
+		response = append(response, followResponse{
+			User_id:     f.UserID,
+			Followed_at: f.FollowedAt,
+		})
+	}
+
+	setNextPageHeaders(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerGetFollowing(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	following, err := cfg.dbQueries.ListFollowing(r.Context(), database.ListFollowingParams{
+		UserID:          userID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageLimit:       page.queryLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting followed users", err)
+		return
+	}
+
+	following, nextCursor := trimPage(following, page.Limit, func(f database.ListFollowingRow) pageCursor {
+		return pageCursor{CreatedAt: f.FollowedAt, ID: f.UserID}
+	})
+
+	response := []followResponse{}
+	for _, f := range following {
+		response = append(response, followResponse{
+			User_id:     f.UserID,
+			Followed_at: f.FollowedAt,
+		})
+	}
+
+	setNextPageHeaders(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerGetTimeline(w http.ResponseWriter, r *http.Request) {
+	v, err := cfg.authenticateViewer(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	chirps, err := cfg.dbQueries.ListTimelineChirps(r.Context(), database.ListTimelineChirpsParams{
+		UserID:            v.ID.UUID,
+		ViewerIsModerator: v.Moderator,
+		CursorCreatedAt:   page.cursorCreatedAt(),
+		CursorID:          page.cursorID(),
+		PageLimit:         page.queryLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting timeline", err)
+		return
+	}
+
+	chirps, nextCursor := trimPage(chirps, page.Limit, chirpCursor)
+
+	response := newChirpResponses(chirps)
+	err = cfg.decorateChirps(r.Context(), response, v)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting chirp details", err)
+		return
+	}
+
+	setNextPageHeaders(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -41,46 +42,59 @@ func CheckPasswordHash(hash, password string) error {
 	return nil
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	signingKey := []byte(tokenSecret)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    string(TokenTypeAccess),
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
-	})
-	return token.SignedString(signingKey)
+// Claims are the claims of an access token. Roles lists the roles the
+// user held when the token was minted, SessionID the refresh token family
+// it was minted for.
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles     []string `json:"roles,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimStruct := jwt.RegisteredClaims{}
-	token, err := jwt.ParseWithClaims(
-		tokenString,
-		&claimStruct,
-		func(token *jwt.Token) (interface{}, error) { return []byte(tokenSecret), nil },
-	)
+// UserID returns the user the token was issued to.
+func (c *Claims) UserID() (uuid.UUID, error) {
+	id, err := uuid.Parse(c.Subject)
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, fmt.Errorf("invalid user ID: %v", err)
 	}
+	return id, nil
+}
 
-	userIDString, err := token.Claims.GetSubject()
+// Session returns the session the token belongs to, or uuid.Nil for
+// tokens minted outside of one.
+func (c *Claims) Session() uuid.UUID {
+	id, err := uuid.Parse(c.SessionID)
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil
 	}
+	return id
+}
 
-	issuer, err := token.Claims.GetIssuer()
-	if err != nil {
-		return uuid.Nil, err
-	}
-	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
-	}
+// MakeJWT signs an access token with HS256 and a shared secret. The server
+// signs with a Keyring instead; see Keyring.MakeJWT.
+func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration, roles ...string) (string, error) {
+	return MakeSessionJWT(userID, uuid.Nil, tokenSecret, expiresIn, roles...)
+}
+
+// MakeSessionJWT is MakeJWT for a token tied to a session, which stops
+// working as soon as the session is revoked.
+func MakeSessionJWT(userID, sessionID uuid.UUID, tokenSecret string, expiresIn time.Duration, roles ...string) (string, error) {
+	keyring, _ := NewKeyring(NewHMACKey(tokenSecret))
+	return keyring.MakeSessionJWT(userID, sessionID, expiresIn, roles...)
+}
+
+// ParseJWT validates an HS256 access token and returns all of its claims.
+func ParseJWT(tokenString, tokenSecret string) (*Claims, error) {
+	keyring, _ := NewKeyring(NewHMACKey(tokenSecret))
+	return keyring.ParseJWT(tokenString)
+}
 
-	id, err := uuid.Parse(userIDString)
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	claims, err := ParseJWT(tokenString, tokenSecret)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID: %v", err)
+		return uuid.Nil, err
 	}
-	return id, nil
+	return claims.UserID()
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -101,6 +115,32 @@ func MakeRefreshToken() (string, error) {
 	return tokenString, nil
 }
 
+// PersonalAccessTokenPrefix starts every personal access token, which
+// tells them apart from JWTs and makes leaked ones easy to scan for.
+const PersonalAccessTokenPrefix = "chirpy_pat_"
+
+// MakePersonalAccessToken returns a new random personal access token.
+func MakePersonalAccessToken() (string, error) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + token, nil
+}
+
+// IsPersonalAccessToken reports whether a bearer token is a personal access
+// token rather than a JWT.
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// HashToken returns the hex encoded SHA-256 of a random token, for storing
+// single use tokens without keeping them in a usable form.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	apiKey := headers.Get("Authorization")
 	if apiKey == "" {
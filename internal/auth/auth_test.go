@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"slices"
 	"testing"
 	"time"
 
@@ -108,3 +109,143 @@ func TestValidateJWT(t *testing.T) {
 		})
 	}
 }
+
+func TestParseJWTRoles(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name      string
+		roles     []string
+		wantRoles []string
+	}{
+		{
+			name:      "No roles",
+			roles:     nil,
+			wantRoles: nil,
+		},
+		{
+			name:      "Single role",
+			roles:     []string{"moderator"},
+			wantRoles: []string{"moderator"},
+		},
+		{
+			name:      "Several roles",
+			roles:     []string{"admin", "moderator"},
+			wantRoles: []string{"admin", "moderator"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := MakeJWT(userID, "secret", time.Hour, tt.roles...)
+			if err != nil {
+				t.Fatalf("MakeJWT() error = %v", err)
+			}
+
+			claims, err := ParseJWT(token, "secret")
+			if err != nil {
+				t.Fatalf("ParseJWT() error = %v", err)
+			}
+			if gotUserID, _ := claims.UserID(); gotUserID != userID {
+				t.Errorf("ParseJWT() user ID = %v, want %v", gotUserID, userID)
+			}
+			if !slices.Equal(claims.Roles, tt.wantRoles) {
+				t.Errorf("ParseJWT() roles = %v, want %v", claims.Roles, tt.wantRoles)
+			}
+		})
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{
+			name:  "Known digest",
+			token: "abc",
+			want:  "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		},
+		{
+			name:  "Empty token",
+			token: "",
+			want:  "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HashToken(tt.token); got != tt.want {
+				t.Errorf("HashToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeSessionJWT(t *testing.T) {
+	userID := uuid.New()
+	sessionID := uuid.New()
+
+	tests := []struct {
+		name        string
+		sessionID   uuid.UUID
+		wantSession uuid.UUID
+	}{
+		{
+			name:        "With session",
+			sessionID:   sessionID,
+			wantSession: sessionID,
+		},
+		{
+			name:        "Without session",
+			sessionID:   uuid.Nil,
+			wantSession: uuid.Nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := MakeSessionJWT(userID, tt.sessionID, "secret", time.Hour)
+			if err != nil {
+				t.Fatalf("MakeSessionJWT() error = %v", err)
+			}
+
+			claims, err := ParseJWT(token, "secret")
+			if err != nil {
+				t.Fatalf("ParseJWT() error = %v", err)
+			}
+			if got := claims.Session(); got != tt.wantSession {
+				t.Errorf("Session() = %v, want %v", got, tt.wantSession)
+			}
+		})
+	}
+}
+
+func TestIsPersonalAccessToken(t *testing.T) {
+	pat, err := MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("MakePersonalAccessToken() error = %v", err)
+	}
+	jwt, _ := MakeJWT(uuid.New(), "secret", time.Hour)
+	refreshToken, _ := MakeRefreshToken()
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"Personal access token", pat, true},
+		{"JWT", jwt, false},
+		{"Refresh token", refreshToken, false},
+		{"Empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPersonalAccessToken(tt.token); got != tt.want {
+				t.Errorf("IsPersonalAccessToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
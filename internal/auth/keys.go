@@ -0,0 +1,350 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Signing algorithms a Key can be generated for.
+const (
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmRS256 = "RS256"
+
+	minRSABits = 2048
+)
+
+// Key signs or verifies access tokens. Asymmetric keys are identified by
+// their RFC 7638 thumbprint, which is sent as the token's kid header.
+type Key struct {
+	ID      string
+	method  jwt.SigningMethod
+	private any
+	public  any
+}
+
+// NewHMACKey returns an HS256 key for a shared secret. It has no ID, so it
+// verifies tokens without a kid header.
+func NewHMACKey(secret string) *Key {
+	return &Key{
+		method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}
+}
+
+// GenerateKey creates a new private key for AlgorithmEdDSA or
+// AlgorithmRS256.
+func GenerateKey(algorithm string) (*Key, error) {
+	switch algorithm {
+	case AlgorithmEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return newSignerKey(private)
+	case AlgorithmRS256:
+		private, err := rsa.GenerateKey(rand.Reader, minRSABits)
+		if err != nil {
+			return nil, err
+		}
+		return newSignerKey(private)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+}
+
+func newSignerKey(private crypto.Signer) (*Key, error) {
+	key := &Key{private: private}
+	switch public := private.Public().(type) {
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+		key.public = public
+	case *rsa.PublicKey:
+		if public.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA keys need at least %d bits, got %d", minRSABits, public.N.BitLen())
+		}
+		key.method = jwt.SigningMethodRS256
+		key.public = public
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", public)
+	}
+
+	jwk, _ := key.publicJWK()
+	key.ID = jwk.thumbprint()
+	return key, nil
+}
+
+// Algorithm returns the JWS algorithm the key signs with.
+func (k *Key) Algorithm() string {
+	return k.method.Alg()
+}
+
+// ParseKeyPEM reads an Ed25519 or RSA private key in PKCS #8 form, or an
+// RSA key in PKCS #1 form as written by openssl genrsa.
+func ParseKeyPEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var private any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q, want a private key", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", private)
+	}
+	return newSignerKey(signer)
+}
+
+// MarshalPEM encodes the private key in PKCS #8 form.
+func (k *Key) MarshalPEM() ([]byte, error) {
+	if _, ok := k.private.([]byte); ok {
+		return nil, errors.New("HMAC keys can't be written as PEM")
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// LoadKeyFile reads a PEM encoded private key.
+func LoadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// LoadOrGenerateKeyFile reads the private key at path. If there is none, it
+// generates one for algorithm, saves it there readable only by the owner
+// and reports that it did.
+func LoadOrGenerateKeyFile(path, algorithm string) (*Key, bool, error) {
+	key, err := LoadKeyFile(path)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return key, false, err
+	}
+
+	key, err = GenerateKey(algorithm)
+	if err != nil {
+		return nil, false, err
+	}
+	data, err := key.MarshalPEM()
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return nil, false, err
+	}
+	// O_EXCL keeps two servers starting at once from overwriting each
+	// other's key.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		key, err = LoadKeyFile(path)
+		return key, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return key, true, nil
+}
+
+// JWK is a public key in the JSON Web Key format of RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set as served from /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// publicJWK returns the public half of k, or false for HMAC keys which have
+// nothing to publish.
+func (k *Key) publicJWK() (JWK, bool) {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch public := k.public.(type) {
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Use: "sig", Alg: k.Algorithm(), Kid: k.ID, Crv: "Ed25519", X: encode(public)}, true
+	case *rsa.PublicKey:
+		e := big.NewInt(int64(public.E)).Bytes()
+		return JWK{Kty: "RSA", Use: "sig", Alg: k.Algorithm(), Kid: k.ID, N: encode(public.N.Bytes()), E: encode(e)}, true
+	default:
+		return JWK{}, false
+	}
+}
+
+// thumbprint computes the RFC 7638 thumbprint over the key's required
+// members in lexicographic order. They are all base64url, so nothing needs
+// escaping.
+func (j JWK) thumbprint() string {
+	var canonical string
+	switch j.Kty {
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":"%s","kty":"OKP","x":"%s"}`, j.Crv, j.X)
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, j.E, j.N)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Keyring signs access tokens with its active key and verifies them with
+// any of its keys, so tokens signed before a rotation stay valid until
+// they expire.
+type Keyring struct {
+	active  *Key
+	ordered []*Key
+	keys    map[string]*Key
+	methods []string
+}
+
+// NewKeyring returns a keyring signing with active. Previous keys are only
+// used to verify; listing the active key among them is harmless.
+func NewKeyring(active *Key, previous ...*Key) (*Keyring, error) {
+	if active == nil {
+		return nil, errors.New("keyring needs an active key")
+	}
+
+	k := &Keyring{
+		active: active,
+		keys:   map[string]*Key{},
+	}
+	for _, key := range append([]*Key{active}, previous...) {
+		if _, ok := k.keys[key.ID]; ok {
+			continue
+		}
+		k.ordered = append(k.ordered, key)
+		k.keys[key.ID] = key
+		if !slices.Contains(k.methods, key.Algorithm()) {
+			k.methods = append(k.methods, key.Algorithm())
+		}
+	}
+	return k, nil
+}
+
+// Active returns the key new tokens are signed with.
+func (k *Keyring) Active() *Key {
+	return k.active
+}
+
+// MakeJWT mints an access token outside of a session.
+func (k *Keyring) MakeJWT(userID uuid.UUID, expiresIn time.Duration, roles ...string) (string, error) {
+	return k.MakeSessionJWT(userID, uuid.Nil, expiresIn, roles...)
+}
+
+// MakeSessionJWT mints an access token tied to a session, which stops
+// working as soon as the session is revoked.
+func (k *Keyring) MakeSessionJWT(userID, sessionID uuid.UUID, expiresIn time.Duration, roles ...string) (string, error) {
+	var sid string
+	if sessionID != uuid.Nil {
+		sid = sessionID.String()
+	}
+
+	token := jwt.NewWithClaims(k.active.method, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeAccess),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+		Roles:     roles,
+		SessionID: sid,
+	})
+	if k.active.ID != "" {
+		token.Header["kid"] = k.active.ID
+	}
+	return token.SignedString(k.active.private)
+}
+
+// ParseJWT validates an access token and returns all of its claims. The
+// kid header picks the key, and the token's algorithm has to be the one
+// that key signs with.
+func (k *Keyring) ParseJWT(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			key, ok := k.keys[kid]
+			if !ok {
+				return nil, fmt.Errorf("unknown signing key %q", kid)
+			}
+			if token.Method.Alg() != key.Algorithm() {
+				return nil, fmt.Errorf("key %q doesn't sign with %s", kid, token.Method.Alg())
+			}
+			return key.public, nil
+		},
+		jwt.WithValidMethods(k.methods),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Issuer != string(TokenTypeAccess) {
+		return nil, errors.New("invalid issuer")
+	}
+	return claims, nil
+}
+
+// JWKS returns the public keys of the keyring, active key first.
+func (k *Keyring) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range k.ordered {
+		if jwk, ok := key.publicJWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
@@ -0,0 +1,284 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestJWKThumbprint(t *testing.T) {
+	tests := []struct {
+		name string
+		jwk  JWK
+		want string
+	}{
+		{
+			// RFC 7638 section 3.1.
+			name: "RSA",
+			jwk: JWK{
+				Kty: "RSA",
+				N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+				E:   "AQAB",
+				Alg: "RS256",
+				Kid: "2011-04-29",
+			},
+			want: "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			// RFC 8037 appendix A.3.
+			name: "Ed25519",
+			jwk: JWK{
+				Kty: "OKP",
+				Crv: "Ed25519",
+				X:   "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+			},
+			want: "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.jwk.thumbprint(); got != tt.want {
+				t.Errorf("thumbprint() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyringRoundTrip(t *testing.T) {
+	userID := uuid.New()
+	sessionID := uuid.New()
+
+	for _, algorithm := range []string{AlgorithmEdDSA, AlgorithmRS256} {
+		t.Run(algorithm, func(t *testing.T) {
+			key, err := GenerateKey(algorithm)
+			if err != nil {
+				t.Fatalf("GenerateKey() error = %v", err)
+			}
+			keyring, err := NewKeyring(key)
+			if err != nil {
+				t.Fatalf("NewKeyring() error = %v", err)
+			}
+
+			token, err := keyring.MakeSessionJWT(userID, sessionID, time.Hour, "moderator")
+			if err != nil {
+				t.Fatalf("MakeSessionJWT() error = %v", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+			if err != nil {
+				t.Fatalf("ParseUnverified() error = %v", err)
+			}
+			if parsed.Header["kid"] != key.ID || parsed.Header["alg"] != algorithm {
+				t.Errorf("header = %v, want kid %s and alg %s", parsed.Header, key.ID, algorithm)
+			}
+
+			claims, err := keyring.ParseJWT(token)
+			if err != nil {
+				t.Fatalf("ParseJWT() error = %v", err)
+			}
+			if got, _ := claims.UserID(); got != userID {
+				t.Errorf("UserID() = %v, want %v", got, userID)
+			}
+			if claims.Session() != sessionID {
+				t.Errorf("Session() = %v, want %v", claims.Session(), sessionID)
+			}
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	userID := uuid.New()
+	oldKey, _ := GenerateKey(AlgorithmRS256)
+	newKey, _ := GenerateKey(AlgorithmEdDSA)
+	unknownKey, _ := GenerateKey(AlgorithmEdDSA)
+	legacyKey := NewHMACKey("secret")
+
+	oldRing, _ := NewKeyring(oldKey)
+	rotated, _ := NewKeyring(newKey, oldKey, legacyKey)
+	unknownRing, _ := NewKeyring(unknownKey)
+
+	sign := func(keyring *Keyring) string {
+		token, err := keyring.MakeJWT(userID, time.Hour)
+		if err != nil {
+			t.Fatalf("MakeJWT() error = %v", err)
+		}
+		return token
+	}
+	legacyToken, _ := MakeJWT(userID, "secret", time.Hour)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"Active key", sign(rotated), false},
+		{"Previous key", sign(oldRing), false},
+		{"Legacy HMAC token without kid", legacyToken, false},
+		{"Key not in the ring", sign(unknownRing), true},
+		{"Expired", func() string {
+			token, _ := rotated.MakeJWT(userID, -time.Minute)
+			return token
+		}(), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := rotated.ParseJWT(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseJWT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyringRejectsAlgorithmConfusion(t *testing.T) {
+	key, _ := GenerateKey(AlgorithmEdDSA)
+	keyring, _ := NewKeyring(key, NewHMACKey("secret"))
+	public := key.public.(ed25519.PublicKey)
+
+	tests := []struct {
+		name   string
+		method jwt.SigningMethod
+		kid    string
+		secret []byte
+	}{
+		{
+			// An attacker who knows the public key signs with it as an
+			// HMAC secret and names the asymmetric key.
+			name:   "HMAC with the public key",
+			method: jwt.SigningMethodHS256,
+			kid:    key.ID,
+			secret: public,
+		},
+		{
+			name:   "HMAC with the public key and no kid",
+			method: jwt.SigningMethodHS256,
+			secret: public,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.NewWithClaims(tt.method, Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer:    string(TokenTypeAccess),
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+					Subject:   uuid.NewString(),
+				},
+			})
+			if tt.kid != "" {
+				token.Header["kid"] = tt.kid
+			}
+			signed, err := token.SignedString(tt.secret)
+			if err != nil {
+				t.Fatalf("SignedString() error = %v", err)
+			}
+
+			if _, err := keyring.ParseJWT(signed); err == nil {
+				t.Errorf("ParseJWT() accepted a forged token")
+			}
+		})
+	}
+
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Issuer: string(TokenTypeAccess), Subject: uuid.NewString()},
+	})
+	signed, _ := unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if _, err := keyring.ParseJWT(signed); err == nil {
+		t.Errorf("ParseJWT() accepted an unsigned token")
+	}
+}
+
+func TestKeyPEM(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmEdDSA, AlgorithmRS256} {
+		t.Run(algorithm, func(t *testing.T) {
+			key, _ := GenerateKey(algorithm)
+			data, err := key.MarshalPEM()
+			if err != nil {
+				t.Fatalf("MarshalPEM() error = %v", err)
+			}
+
+			parsed, err := ParseKeyPEM(data)
+			if err != nil {
+				t.Fatalf("ParseKeyPEM() error = %v", err)
+			}
+			if parsed.ID != key.ID || parsed.Algorithm() != algorithm {
+				t.Errorf("ParseKeyPEM() = %s %s, want %s %s", parsed.ID, parsed.Algorithm(), key.ID, algorithm)
+			}
+		})
+	}
+
+	if _, err := ParseKeyPEM([]byte("not a key")); err == nil {
+		t.Errorf("ParseKeyPEM() accepted garbage")
+	}
+	if _, err := NewHMACKey("secret").MarshalPEM(); err == nil {
+		t.Errorf("MarshalPEM() wrote an HMAC key")
+	}
+}
+
+func TestLoadOrGenerateKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "signing.pem")
+
+	key, generated, err := LoadOrGenerateKeyFile(path, AlgorithmEdDSA)
+	if err != nil {
+		t.Fatalf("LoadOrGenerateKeyFile() error = %v", err)
+	}
+	if !generated {
+		t.Errorf("LoadOrGenerateKeyFile() didn't report generating a key")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("key file mode = %v, want 0600", perm)
+	}
+
+	loaded, generated, err := LoadOrGenerateKeyFile(path, AlgorithmRS256)
+	if err != nil {
+		t.Fatalf("LoadOrGenerateKeyFile() error = %v", err)
+	}
+	if generated || loaded.ID != key.ID || loaded.Algorithm() != AlgorithmEdDSA {
+		t.Errorf("LoadOrGenerateKeyFile() = %s generated=%v, want the saved key %s", loaded.ID, generated, key.ID)
+	}
+
+	broken := filepath.Join(t.TempDir(), "broken.pem")
+	os.WriteFile(broken, []byte("garbage"), 0o600)
+	if _, _, err := LoadOrGenerateKeyFile(broken, AlgorithmEdDSA); err == nil {
+		t.Errorf("LoadOrGenerateKeyFile() replaced an unreadable key")
+	}
+}
+
+func TestKeyringJWKS(t *testing.T) {
+	active, _ := GenerateKey(AlgorithmEdDSA)
+	previous, _ := GenerateKey(AlgorithmRS256)
+	keyring, _ := NewKeyring(active, previous, NewHMACKey("secret"), active)
+
+	set := keyring.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS() has %d keys, want 2", len(set.Keys))
+	}
+
+	okp, rsaKey := set.Keys[0], set.Keys[1]
+	if okp.Kid != active.ID || okp.Kty != "OKP" || okp.Crv != "Ed25519" || okp.Alg != AlgorithmEdDSA {
+		t.Errorf("JWKS() first key = %+v, want the active Ed25519 key", okp)
+	}
+	x, _ := base64.RawURLEncoding.DecodeString(okp.X)
+	if !bytes.Equal(x, active.public.(ed25519.PublicKey)) {
+		t.Errorf("JWKS() x doesn't hold the public key")
+	}
+	if rsaKey.Kid != previous.ID || rsaKey.Kty != "RSA" || rsaKey.E != "AQAB" || rsaKey.N == "" {
+		t.Errorf("JWKS() second key = %+v, want the previous RSA key", rsaKey)
+	}
+	if okp.thumbprint() != okp.Kid || rsaKey.thumbprint() != rsaKey.Kid {
+		t.Errorf("JWKS() key IDs aren't the thumbprints")
+	}
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 seed "12345678901234567890" from the test
+// vectors of RFC 6238, base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226 appendix D.
+	key := []byte("12345678901234567890")
+	want := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+
+	for counter, code := range want {
+		if got := hotp(key, uint64(counter), 6); got != code {
+			t.Errorf("hotp(%d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA1. The RFC lists eight digits; six digit
+	// codes are the same value modulo 10^6.
+	tests := []struct {
+		name      string
+		unix      int64
+		want8     string
+		wantCode  string
+		wantSteps int64
+	}{
+		{"59", 59, "94287082", "287082", 1},
+		{"1111111109", 1111111109, "07081804", "081804", 37037036},
+		{"1111111111", 1111111111, "14050471", "050471", 37037037},
+		{"1234567890", 1234567890, "89005924", "005924", 41152263},
+		{"2000000000", 2000000000, "69279037", "279037", 66666666},
+		{"20000000000", 20000000000, "65353130", "353130", 666666666},
+	}
+
+	key := []byte("12345678901234567890")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(tt.unix, 0)
+
+			if got := TOTPStep(now); got != tt.wantSteps {
+				t.Errorf("TOTPStep() = %d, want %d", got, tt.wantSteps)
+			}
+			if got := hotp(key, uint64(TOTPStep(now)), 8); got != tt.want8 {
+				t.Errorf("hotp() = %s, want %s", got, tt.want8)
+			}
+
+			got, err := TOTPCode(rfc6238Secret, now)
+			if err != nil {
+				t.Fatalf("TOTPCode() error = %v", err)
+			}
+			if got != tt.wantCode {
+				t.Errorf("TOTPCode() = %s, want %s", got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, _ := TOTPCode(rfc6238Secret, now)
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		at       time.Time
+		wantStep int64
+		wantOK   bool
+	}{
+		{
+			name:     "Current step",
+			secret:   rfc6238Secret,
+			code:     code,
+			at:       now,
+			wantStep: 37037037,
+			wantOK:   true,
+		},
+		{
+			name:     "Spaces are ignored",
+			secret:   rfc6238Secret,
+			code:     code[:3] + " " + code[3:],
+			at:       now,
+			wantStep: 37037037,
+			wantOK:   true,
+		},
+		{
+			name:     "Lowercase secret",
+			secret:   strings.ToLower(rfc6238Secret),
+			code:     code,
+			at:       now,
+			wantStep: 37037037,
+			wantOK:   true,
+		},
+		{
+			name:     "One step late",
+			secret:   rfc6238Secret,
+			code:     code,
+			at:       now.Add(TOTPPeriod),
+			wantStep: 37037037,
+			wantOK:   true,
+		},
+		{
+			name:     "One step early",
+			secret:   rfc6238Secret,
+			code:     code,
+			at:       now.Add(-TOTPPeriod),
+			wantStep: 37037037,
+			wantOK:   true,
+		},
+		{
+			name:   "Two steps late",
+			secret: rfc6238Secret,
+			code:   code,
+			at:     now.Add(2 * TOTPPeriod),
+		},
+		{
+			name:   "Wrong code",
+			secret: rfc6238Secret,
+			code:   "000000",
+			at:     now,
+		},
+		{
+			name:   "Wrong length",
+			secret: rfc6238Secret,
+			code:   code[:5],
+			at:     now,
+		},
+		{
+			name:   "Invalid secret",
+			secret: "not base32!",
+			code:   code,
+			at:     now,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(tt.secret, tt.code, tt.at)
+			if ok != tt.wantOK {
+				t.Fatalf("ValidateTOTP() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if step != tt.wantStep {
+				t.Errorf("ValidateTOTP() step = %d, want %d", step, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() error = %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("GenerateTOTPSecret() length = %d, want 32", len(secret))
+	}
+
+	now := time.Now()
+	code, err := TOTPCode(secret, now)
+	if err != nil {
+		t.Fatalf("TOTPCode() error = %v", err)
+	}
+	if _, ok := ValidateTOTP(secret, code, now); !ok {
+		t.Errorf("ValidateTOTP() rejected a fresh code")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI(rfc6238Secret, "Chirpy", "walt@example.com"))
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Errorf("TOTPURI() = %s, want otpauth://totp/...", uri)
+	}
+	if uri.Path != "/Chirpy:walt@example.com" {
+		t.Errorf("TOTPURI() label = %s, want /Chirpy:walt@example.com", uri.Path)
+	}
+	query := uri.Query()
+	if query.Get("secret") != rfc6238Secret || query.Get("issuer") != "Chirpy" {
+		t.Errorf("TOTPURI() query = %s", uri.RawQuery)
+	}
+	if query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("TOTPURI() query = %s", uri.RawQuery)
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	codes, err := MakeRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("MakeRecoveryCodes() error = %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("MakeRecoveryCodes() returned %d codes, want 10", len(codes))
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("MakeRecoveryCodes() code = %q, want xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("MakeRecoveryCodes() returned %q twice", code)
+		}
+		seen[code] = true
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		same  bool
+	}{
+		{"Exact", "k3jd9-x8w2m", true},
+		{"Uppercase", "K3JD9-X8W2M", true},
+		{"Without dash", "k3jd9x8w2m", true},
+		{"With spaces", "k3jd9 x8w2m", true},
+		{"Different", "k3jd9-x8w2n", false},
+	}
+
+	want := HashRecoveryCode("k3jd9-x8w2m")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HashRecoveryCode(tt.input) == want; got != tt.same {
+				t.Errorf("HashRecoveryCode(%q) matches = %v, want %v", tt.input, got, tt.same)
+			}
+		})
+	}
+}
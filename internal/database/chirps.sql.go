@@ -7,29 +7,59 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countChirpDependents = `-- name: CountChirpDependents :one
+SELECT COUNT(*) FROM chirps
+WHERE in_reply_to = $1::uuid OR quote_of = $1::uuid
+`
+
+func (q *Queries) CountChirpDependents(ctx context.Context, id uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpDependents, id)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, thread_id, rechirp_of, quote_of)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, thread_id, deleted_at, rechirp_of, quote_of, edit_count, search_vector, hidden_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	InReplyTo uuid.NullUUID
+	ThreadID  uuid.NullUUID
+	RechirpOf uuid.NullUUID
+	QuoteOf   uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.InReplyTo,
+		arg.ThreadID,
+		arg.RechirpOf,
+		arg.QuoteOf,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +67,14 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.ThreadID,
+		&i.DeletedAt,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.EditCount,
+		&i.SearchVector,
+		&i.HiddenAt,
 	)
 	return i, err
 }
@@ -57,12 +95,19 @@ func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) error
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, deleted_at, rechirp_of, quote_of, edit_count, search_vector, hidden_at FROM chirps
+WHERE id = $1 AND deleted_at IS NULL
+AND (hidden_at IS NULL OR user_id = $2::uuid OR $3::boolean)
 `
 
-func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirp, id)
+type GetChirpParams struct {
+	ID                uuid.UUID
+	ViewerID          uuid.NullUUID
+	ViewerIsModerator bool
+}
+
+func (q *Queries) GetChirp(ctx context.Context, arg GetChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirp, arg.ID, arg.ViewerID, arg.ViewerIsModerator)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -70,17 +115,259 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.ThreadID,
+		&i.DeletedAt,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.EditCount,
+		&i.SearchVector,
+		&i.HiddenAt,
 	)
 	return i, err
 }
 
-const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at ASC
+const getChirpThread = `-- name: GetChirpThread :many
+WITH root AS (
+    SELECT COALESCE(thread_id, id) AS id FROM chirps
+    WHERE chirps.id = $1
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.thread_id, chirps.deleted_at, chirps.rechirp_of, chirps.quote_of, chirps.edit_count, chirps.search_vector, chirps.hidden_at, (
+    SELECT COUNT(*) FROM chirps AS replies
+    WHERE replies.in_reply_to = chirps.id
+) AS reply_count
+FROM chirps, root
+WHERE chirps.id = root.id OR chirps.thread_id = root.id
+ORDER BY chirps.created_at ASC, chirps.id ASC
+`
+
+type GetChirpThreadRow struct {
+	Chirp      Chirp
+	ReplyCount int64
+}
+
+func (q *Queries) GetChirpThread(ctx context.Context, id uuid.UUID) ([]GetChirpThreadRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpThread, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpThreadRow
+	for rows.Next() {
+		var i GetChirpThreadRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.ThreadID,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.RechirpOf,
+			&i.Chirp.QuoteOf,
+			&i.Chirp.EditCount,
+			&i.Chirp.SearchVector,
+			&i.Chirp.HiddenAt,
+			&i.ReplyCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, deleted_at, rechirp_of, quote_of, edit_count, search_vector, hidden_at FROM chirps
+WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditCount,
+			&i.SearchVector,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hideChirp = `-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW()
+WHERE id = $1 AND hidden_at IS NULL
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, id)
+	return err
+}
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, deleted_at, rechirp_of, quote_of, edit_count, search_vector, hidden_at FROM chirps
+WHERE deleted_at IS NULL
+AND (
+    rechirp_of IS NULL
+    OR rechirp_of IN (
+        SELECT originals.id FROM chirps AS originals
+        WHERE originals.deleted_at IS NULL
+        AND originals.user_id NOT IN (
+            SELECT muted_id FROM mutes WHERE muter_id = $1::uuid
+            UNION
+            SELECT blocked_id FROM blocks WHERE blocker_id = $1::uuid
+        )
+    )
+)
+AND ($2::uuid IS NULL OR user_id = $2::uuid)
+AND (hidden_at IS NULL OR user_id = $1::uuid OR $3::boolean)
+AND user_id NOT IN (
+    SELECT muted_id FROM mutes WHERE muter_id = $1::uuid
+    UNION
+    SELECT blocked_id FROM blocks WHERE blocker_id = $1::uuid
+)
+AND (
+    $4::timestamp IS NULL
+    OR (created_at, id) > ($4::timestamp, $5::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT $6
+`
+
+type ListChirpsAscParams struct {
+	ViewerID          uuid.NullUUID
+	AuthorID          uuid.NullUUID
+	ViewerIsModerator bool
+	CursorCreatedAt   sql.NullTime
+	CursorID          uuid.NullUUID
+	PageLimit         int32
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc,
+		arg.ViewerID,
+		arg.AuthorID,
+		arg.ViewerIsModerator,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditCount,
+			&i.SearchVector,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, deleted_at, rechirp_of, quote_of, edit_count, search_vector, hidden_at FROM chirps
+WHERE deleted_at IS NULL
+AND (
+    rechirp_of IS NULL
+    OR rechirp_of IN (
+        SELECT originals.id FROM chirps AS originals
+        WHERE originals.deleted_at IS NULL
+        AND originals.user_id NOT IN (
+            SELECT muted_id FROM mutes WHERE muter_id = $1::uuid
+            UNION
+            SELECT blocked_id FROM blocks WHERE blocker_id = $1::uuid
+        )
+    )
+)
+AND ($2::uuid IS NULL OR user_id = $2::uuid)
+AND (hidden_at IS NULL OR user_id = $1::uuid OR $3::boolean)
+AND user_id NOT IN (
+    SELECT muted_id FROM mutes WHERE muter_id = $1::uuid
+    UNION
+    SELECT blocked_id FROM blocks WHERE blocker_id = $1::uuid
+)
+AND (
+    $4::timestamp IS NULL
+    OR (created_at, id) < ($4::timestamp, $5::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT $6
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+type ListChirpsDescParams struct {
+	ViewerID          uuid.NullUUID
+	AuthorID          uuid.NullUUID
+	ViewerIsModerator bool
+	CursorCreatedAt   sql.NullTime
+	CursorID          uuid.NullUUID
+	PageLimit         int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.ViewerID,
+		arg.AuthorID,
+		arg.ViewerIsModerator,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -94,6 +381,14 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditCount,
+			&i.SearchVector,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -107,3 +402,280 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	}
 	return items, nil
 }
+
+const listTimelineChirps = `-- name: ListTimelineChirps :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, deleted_at, rechirp_of, quote_of, edit_count, search_vector, hidden_at FROM chirps
+WHERE deleted_at IS NULL
+AND (
+    rechirp_of IS NULL
+    OR rechirp_of IN (
+        SELECT originals.id FROM chirps AS originals
+        WHERE originals.deleted_at IS NULL
+        AND originals.user_id NOT IN (
+            SELECT muted_id FROM mutes WHERE muter_id = $1
+            UNION
+            SELECT blocked_id FROM blocks WHERE blocker_id = $1
+        )
+    )
+)
+AND (
+    user_id = $1
+    OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = $1)
+)
+AND (hidden_at IS NULL OR user_id = $1 OR $2::boolean)
+AND user_id NOT IN (
+    SELECT muted_id FROM mutes WHERE muter_id = $1
+    UNION
+    SELECT blocked_id FROM blocks WHERE blocker_id = $1
+)
+AND (
+    $3::timestamp IS NULL
+    OR (created_at, id) < ($3::timestamp, $4::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT $5
+`
+
+type ListTimelineChirpsParams struct {
+	UserID            uuid.UUID
+	ViewerIsModerator bool
+	CursorCreatedAt   sql.NullTime
+	CursorID          uuid.NullUUID
+	PageLimit         int32
+}
+
+func (q *Queries) ListTimelineChirps(ctx context.Context, arg ListTimelineChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTimelineChirps,
+		arg.UserID,
+		arg.ViewerIsModerator,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditCount,
+			&i.SearchVector,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserChirps = `-- name: ListUserChirps :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, deleted_at, rechirp_of, quote_of, edit_count, search_vector, hidden_at FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL
+ORDER BY created_at, id
+`
+
+func (q *Queries) ListUserChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listUserChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditCount,
+			&i.SearchVector,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.thread_id, chirps.deleted_at, chirps.rechirp_of, chirps.quote_of, chirps.edit_count, chirps.search_vector, chirps.hidden_at,
+    (
+        ts_rank(chirps.search_vector, websearch_to_tsquery('english', $1), 32)
+        / (1 + GREATEST(EXTRACT(EPOCH FROM $2::timestamp - chirps.created_at), 0) / 86400)
+    )::real AS score
+FROM chirps
+WHERE chirps.search_vector @@ websearch_to_tsquery('english', $1)
+AND chirps.deleted_at IS NULL
+AND ($3::uuid IS NULL OR chirps.user_id = $3::uuid)
+AND (chirps.hidden_at IS NULL OR chirps.user_id = $4::uuid OR $5::boolean)
+AND chirps.user_id NOT IN (
+    SELECT muted_id FROM mutes WHERE muter_id = $4::uuid
+    UNION
+    SELECT blocked_id FROM blocks WHERE blocker_id = $4::uuid
+)
+AND (
+    $6::real IS NULL
+    OR (
+        (
+            ts_rank(chirps.search_vector, websearch_to_tsquery('english', $1), 32)
+            / (1 + GREATEST(EXTRACT(EPOCH FROM $2::timestamp - chirps.created_at), 0) / 86400)
+        )::real,
+        chirps.created_at,
+        chirps.id
+    ) < ($6::real, $7::timestamp, $8::uuid)
+)
+ORDER BY score DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT $9
+`
+
+type SearchChirpsParams struct {
+	Query             string
+	RankedAt          time.Time
+	AuthorID          uuid.NullUUID
+	ViewerID          uuid.NullUUID
+	ViewerIsModerator bool
+	CursorScore       sql.NullFloat64
+	CursorCreatedAt   sql.NullTime
+	CursorID          uuid.NullUUID
+	PageLimit         int32
+}
+
+type SearchChirpsRow struct {
+	Chirp Chirp
+	Score float32
+}
+
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Query,
+		arg.RankedAt,
+		arg.AuthorID,
+		arg.ViewerID,
+		arg.ViewerIsModerator,
+		arg.CursorScore,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.ThreadID,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.RechirpOf,
+			&i.Chirp.QuoteOf,
+			&i.Chirp.EditCount,
+			&i.Chirp.SearchVector,
+			&i.Chirp.HiddenAt,
+			&i.Score,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const tombstoneChirp = `-- name: TombstoneChirp :exec
+UPDATE chirps
+SET body = '',
+deleted_at = NOW(),
+updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+`
+
+type TombstoneChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) TombstoneChirp(ctx context.Context, arg TombstoneChirpParams) error {
+	_, err := q.db.ExecContext(ctx, tombstoneChirp, arg.ID, arg.UserID)
+	return err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1,
+edit_count = edit_count + 1,
+updated_at = NOW()
+WHERE id = $2 AND user_id = $3
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, thread_id, deleted_at, rechirp_of, quote_of, edit_count, search_vector, hidden_at
+`
+
+type UpdateChirpBodyParams struct {
+	Body   string
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.Body, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.ThreadID,
+		&i.DeletedAt,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.EditCount,
+		&i.SearchVector,
+		&i.HiddenAt,
+	)
+	return i, err
+}
@@ -11,28 +11,230 @@ import (
 	"github.com/google/uuid"
 )
 
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Body         string
+	UserID       uuid.UUID
+	InReplyTo    uuid.NullUUID
+	ThreadID     uuid.NullUUID
+	DeletedAt    sql.NullTime
+	RechirpOf    uuid.NullUUID
+	QuoteOf      uuid.NullUUID
+	EditCount    int32
+	SearchVector interface{}
+	HiddenAt     sql.NullTime
+}
+
+type ChirpAttachment struct {
+	ChirpID  uuid.UUID
+	MediaID  uuid.UUID
+	Position int32
+}
+
+type ChirpFlag struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Reasons    string
+	CreatedAt  time.Time
+	ResolvedAt sql.NullTime
+}
+
+type ChirpRevision struct {
 	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ChirpID   uuid.UUID
 	Body      string
+	CreatedAt time.Time
+}
+
+type ChirpTag struct {
+	ChirpID   uuid.UUID
+	TagID     uuid.UUID
+	CreatedAt time.Time
+}
+
+type EmailChange struct {
 	UserID    uuid.UUID
+	NewEmail  string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
 }
 
-type RefreshToken struct {
-	Token     string
+type EmailVerification struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Email     string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Like struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type MediaFile struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	ContentType  string
+	Width        int32
+	Height       int32
+	SizeBytes    int64
+	BlobKey      string
+	ThumbnailKey string
+	CreatedAt    time.Time
+}
+
+type Mention struct {
+	ChirpID     uuid.UUID
+	UserID      uuid.UUID
+	StartOffset int32
+	CreatedAt   time.Time
+}
+
+type ModerationAction struct {
+	ID          uuid.UUID
+	ChirpID     uuid.NullUUID
+	ReportID    uuid.NullUUID
+	ModeratorID uuid.NullUUID
+	Action      string
+	Note        string
+	CreatedAt   time.Time
+	ChirpBody   string
+}
+
+type ModerationWord struct {
+	Word      string
+	Action    string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type PasswordReset struct {
+	TokenHash string
 	UserID    uuid.UUID
 	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     []string
+	ExpiresAt  time.Time
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+	CreatedAt  time.Time
+}
+
+type RecoveryCode struct {
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type RefreshToken struct {
+	TokenHash  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	FamilyID   uuid.UUID
+	RotatedAt  sql.NullTime
+	DeviceName string
+	UserAgent  string
+	IpAddress  string
+	LastUsedAt time.Time
+}
+
+type Report struct {
+	ID         uuid.UUID
+	ChirpID    uuid.NullUUID
+	ReporterID uuid.UUID
+	Reason     string
+	Details    string
+	Status     string
+	CreatedAt  time.Time
+	ResolvedAt sql.NullTime
+}
+
+type SecurityEvent struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Event     string
+	Details   string
+	IpAddress string
+	UserAgent string
+	CreatedAt time.Time
+}
+
+type Tag struct {
+	ID        uuid.UUID
+	Name      string
+	CreatedAt time.Time
+}
+
+type TotpCredential struct {
+	UserID       uuid.UUID
+	Secret       string
+	EnabledAt    sql.NullTime
+	LastUsedStep int64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type TwoFactorChallenge struct {
+	TokenHash  string
+	UserID     uuid.UUID
+	DeviceName string
+	Attempts   int32
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    sql.NullBool
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Email               string
+	HashedPassword      string
+	IsChirpyRed         sql.NullBool
+	Handle              sql.NullString
+	SuspendedUntil      sql.NullTime
+	Roles               []string
+	Banned              bool
+	SuspensionReason    string
+	DisplayName         string
+	Bio                 string
+	Location            string
+	Website             string
+	AvatarUrl           string
+	EmailVerifiedAt     sql.NullTime
+	DeletionRequestedAt sql.NullTime
 }
@@ -7,29 +7,67 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const banUser = `-- name: BanUser :execrows
+UPDATE users
+SET banned = true,
+suspension_reason = $1,
+updated_at = NOW()
+WHERE id = $2
+`
+
+type BanUserParams struct {
+	SuspensionReason string
+	ID               uuid.UUID
+}
+
+func (q *Queries) BanUser(ctx context.Context, arg BanUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, banUser, arg.SuspensionReason, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const cancelUserDeletion = `-- name: CancelUserDeletion :exec
+UPDATE users
+SET updated_at = NOW(),
+deletion_requested_at = NULL
+WHERE id = $1 AND deletion_requested_at IS NOT NULL
+`
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, cancelUserDeletion, id)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, suspended_until, roles, banned, suspension_reason, display_name, bio, location, website, avatar_url, email_verified_at, deletion_requested_at
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword string
+	Handle         sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword, arg.Handle)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -38,10 +76,40 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.SuspendedUntil,
+		pq.Array(&i.Roles),
+		&i.Banned,
+		&i.SuspensionReason,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
 	)
 	return i, err
 }
 
+const deleteUserDueForDeletion = `-- name: DeleteUserDueForDeletion :execrows
+DELETE FROM users
+WHERE id = $1 AND deletion_requested_at <= $2
+`
+
+type DeleteUserDueForDeletionParams struct {
+	ID                  uuid.UUID
+	DeletionRequestedAt sql.NullTime
+}
+
+func (q *Queries) DeleteUserDueForDeletion(ctx context.Context, arg DeleteUserDueForDeletionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUserDueForDeletion, arg.ID, arg.DeletionRequestedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteUsers = `-- name: DeleteUsers :exec
 DELETE FROM users
 `
@@ -51,8 +119,48 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 	return err
 }
 
+const getChirpAuthors = `-- name: GetChirpAuthors :many
+SELECT id, handle, display_name, avatar_url FROM users
+WHERE id = ANY($1::uuid[])
+`
+
+type GetChirpAuthorsRow struct {
+	ID          uuid.UUID
+	Handle      sql.NullString
+	DisplayName string
+	AvatarUrl   string
+}
+
+func (q *Queries) GetChirpAuthors(ctx context.Context, ids []uuid.UUID) ([]GetChirpAuthorsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAuthors, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpAuthorsRow
+	for rows.Next() {
+		var i GetChirpAuthorsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Handle,
+			&i.DisplayName,
+			&i.AvatarUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, suspended_until, roles, banned, suspension_reason, display_name, bio, location, website, avatar_url, email_verified_at, deletion_requested_at FROM users
 WHERE email = $1
 `
 
@@ -66,10 +174,339 @@ func (q *Queries) GetUser(ctx context.Context, email string) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.SuspendedUntil,
+		pq.Array(&i.Roles),
+		&i.Banned,
+		&i.SuspensionReason,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, suspended_until, roles, banned, suspension_reason, display_name, bio, location, website, avatar_url, email_verified_at, deletion_requested_at FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.SuspendedUntil,
+		pq.Array(&i.Roles),
+		&i.Banned,
+		&i.SuspensionReason,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
+	)
+	return i, err
+}
+
+const getUserProfile = `-- name: GetUserProfile :one
+SELECT id, created_at, handle, is_chirpy_red, display_name, bio, location, website, avatar_url,
+    (SELECT COUNT(*) FROM follows WHERE followee_id = users.id) AS follower_count,
+    (SELECT COUNT(*) FROM follows WHERE follower_id = users.id) AS following_count
+FROM users
+WHERE id = $1
+`
+
+type GetUserProfileRow struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	Handle         sql.NullString
+	IsChirpyRed    sql.NullBool
+	DisplayName    string
+	Bio            string
+	Location       string
+	Website        string
+	AvatarUrl      string
+	FollowerCount  int64
+	FollowingCount int64
+}
+
+func (q *Queries) GetUserProfile(ctx context.Context, id uuid.UUID) (GetUserProfileRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserProfile, id)
+	var i GetUserProfileRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Handle,
+		&i.IsChirpyRed,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.FollowerCount,
+		&i.FollowingCount,
+	)
+	return i, err
+}
+
+const getUserStanding = `-- name: GetUserStanding :one
+SELECT banned, suspended_until, deletion_requested_at FROM users
+WHERE id = $1
+`
+
+type GetUserStandingRow struct {
+	Banned              bool
+	SuspendedUntil      sql.NullTime
+	DeletionRequestedAt sql.NullTime
+}
+
+func (q *Queries) GetUserStanding(ctx context.Context, id uuid.UUID) (GetUserStandingRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserStanding, id)
+	var i GetUserStandingRow
+	err := row.Scan(&i.Banned, &i.SuspendedUntil, &i.DeletionRequestedAt)
+	return i, err
+}
+
+const getUsersByHandles = `-- name: GetUsersByHandles :many
+SELECT id, handle FROM users
+WHERE handle = ANY($1::text[])
+AND id NOT IN (SELECT blocker_id FROM blocks WHERE blocked_id = $2)
+`
+
+type GetUsersByHandlesParams struct {
+	Handles  []string
+	AuthorID uuid.UUID
+}
+
+type GetUsersByHandlesRow struct {
+	ID     uuid.UUID
+	Handle sql.NullString
+}
+
+func (q *Queries) GetUsersByHandles(ctx context.Context, arg GetUsersByHandlesParams) ([]GetUsersByHandlesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByHandles, pq.Array(arg.Handles), arg.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUsersByHandlesRow
+	for rows.Next() {
+		var i GetUsersByHandlesRow
+		if err := rows.Scan(&i.ID, &i.Handle); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const grantUserRole = `-- name: GrantUserRole :execrows
+UPDATE users
+SET roles = CASE
+    WHEN $1::text = ANY(roles) THEN roles
+    ELSE array_append(roles, $1::text)
+END,
+updated_at = NOW()
+WHERE id = $2
+`
+
+type GrantUserRoleParams struct {
+	Role string
+	ID   uuid.UUID
+}
+
+func (q *Queries) GrantUserRole(ctx context.Context, arg GrantUserRoleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, grantUserRole, arg.Role, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listSuspendedUsers = `-- name: ListSuspendedUsers :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, suspended_until, roles, banned, suspension_reason, display_name, bio, location, website, avatar_url, email_verified_at, deletion_requested_at FROM users
+WHERE (banned OR suspended_until > NOW())
+AND (
+    $1::timestamp IS NULL
+    OR (created_at, id) < ($1::timestamp, $2::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT $3
+`
+
+type ListSuspendedUsersParams struct {
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+func (q *Queries) ListSuspendedUsers(ctx context.Context, arg ListSuspendedUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listSuspendedUsers, arg.CursorCreatedAt, arg.CursorID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.Handle,
+			&i.SuspendedUntil,
+			pq.Array(&i.Roles),
+			&i.Banned,
+			&i.SuspensionReason,
+			&i.DisplayName,
+			&i.Bio,
+			&i.Location,
+			&i.Website,
+			&i.AvatarUrl,
+			&i.EmailVerifiedAt,
+			&i.DeletionRequestedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersDueForDeletion = `-- name: ListUsersDueForDeletion :many
+SELECT id FROM users
+WHERE deletion_requested_at <= $1
+ORDER BY deletion_requested_at
+LIMIT $2
+`
+
+type ListUsersDueForDeletionParams struct {
+	DeletionRequestedAt sql.NullTime
+	Limit               int32
+}
+
+func (q *Queries) ListUsersDueForDeletion(ctx context.Context, arg ListUsersDueForDeletionParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersDueForDeletion, arg.DeletionRequestedAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :one
+UPDATE users
+SET updated_at = NOW(),
+email_verified_at = COALESCE(email_verified_at, NOW())
+WHERE id = $1 AND email = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, suspended_until, roles, banned, suspension_reason, display_name, bio, location, website, avatar_url, email_verified_at, deletion_requested_at
+`
+
+type MarkUserEmailVerifiedParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, arg MarkUserEmailVerifiedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, markUserEmailVerified, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.SuspendedUntil,
+		pq.Array(&i.Roles),
+		&i.Banned,
+		&i.SuspensionReason,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
 	)
 	return i, err
 }
 
+const requestUserDeletion = `-- name: RequestUserDeletion :exec
+UPDATE users
+SET updated_at = NOW(),
+deletion_requested_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) RequestUserDeletion(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, requestUserDeletion, id)
+	return err
+}
+
+const revokeUserRole = `-- name: RevokeUserRole :execrows
+UPDATE users
+SET roles = array_remove(roles, $1::text),
+updated_at = NOW()
+WHERE id = $2
+`
+
+type RevokeUserRoleParams struct {
+	Role string
+	ID   uuid.UUID
+}
+
+func (q *Queries) RevokeUserRole(ctx context.Context, arg RevokeUserRoleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserRole, arg.Role, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const setUserChirpyRed = `-- name: SetUserChirpyRed :exec
 UPDATE users
 SET is_chirpy_red = true
@@ -81,23 +518,184 @@ func (q *Queries) SetUserChirpyRed(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const suspendUser = `-- name: SuspendUser :execrows
+UPDATE users
+SET suspended_until = $1,
+suspension_reason = $2,
+updated_at = NOW()
+WHERE id = $3
+`
+
+type SuspendUserParams struct {
+	SuspendedUntil   sql.NullTime
+	SuspensionReason string
+	ID               uuid.UUID
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, suspendUser, arg.SuspendedUntil, arg.SuspensionReason, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :execrows
+UPDATE users
+SET suspended_until = NULL,
+banned = false,
+suspension_reason = '',
+updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unsuspendUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET updated_at = NOW(),
+email_verified_at = CASE WHEN email = $1 THEN email_verified_at END,
 email = $1,
-hashed_password = $2
-WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+hashed_password = $2,
+handle = COALESCE($3, handle)
+WHERE id = $4
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, suspended_until, roles, banned, suspension_reason, display_name, bio, location, website, avatar_url, email_verified_at, deletion_requested_at
 `
 
 type UpdateUserParams struct {
 	Email          string
 	HashedPassword string
+	Handle         sql.NullString
 	ID             uuid.UUID
 }
 
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUser, arg.Email, arg.HashedPassword, arg.ID)
+	row := q.db.QueryRowContext(ctx, updateUser,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Handle,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.SuspendedUntil,
+		pq.Array(&i.Roles),
+		&i.Banned,
+		&i.SuspensionReason,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
+	)
+	return i, err
+}
+
+const updateUserEmail = `-- name: UpdateUserEmail :one
+UPDATE users
+SET updated_at = NOW(),
+email = $1,
+email_verified_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, suspended_until, roles, banned, suspension_reason, display_name, bio, location, website, avatar_url, email_verified_at, deletion_requested_at
+`
+
+type UpdateUserEmailParams struct {
+	Email string
+	ID    uuid.UUID
+}
+
+func (q *Queries) UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserEmail, arg.Email, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.SuspendedUntil,
+		pq.Array(&i.Roles),
+		&i.Banned,
+		&i.SuspensionReason,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET updated_at = NOW(),
+hashed_password = $1
+WHERE id = $2
+`
+
+type UpdateUserPasswordParams struct {
+	HashedPassword string
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.HashedPassword, arg.ID)
+	return err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET updated_at = NOW(),
+handle = COALESCE($1, handle),
+display_name = COALESCE($2, display_name),
+bio = COALESCE($3, bio),
+location = COALESCE($4, location),
+website = COALESCE($5, website),
+avatar_url = COALESCE($6, avatar_url)
+WHERE id = $7
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, suspended_until, roles, banned, suspension_reason, display_name, bio, location, website, avatar_url, email_verified_at, deletion_requested_at
+`
+
+type UpdateUserProfileParams struct {
+	Handle      sql.NullString
+	DisplayName sql.NullString
+	Bio         sql.NullString
+	Location    sql.NullString
+	Website     sql.NullString
+	AvatarUrl   sql.NullString
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.Location,
+		arg.Website,
+		arg.AvatarUrl,
+		arg.ID,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -106,6 +704,18 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.SuspendedUntil,
+		pq.Array(&i.Roles),
+		&i.Banned,
+		&i.SuspensionReason,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarUrl,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
 	)
 	return i, err
 }
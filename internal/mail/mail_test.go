@@ -0,0 +1,181 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		msg      Message
+		wantErr  bool
+		contains []string
+	}{
+		{
+			name: "Plain message",
+			msg:  Message{To: "user@example.com", Subject: "Hello", Body: "line one\nline two"},
+			contains: []string{
+				"From: chirpy@example.com\r\n",
+				"To: user@example.com\r\n",
+				"Subject: Hello\r\n",
+				"Date: Fri, 01 Mar 2024 12:00:00 +0000\r\n",
+				"\r\n\r\nline one\r\nline two\r\n",
+			},
+		},
+		{
+			name:     "Non ASCII subject is encoded",
+			msg:      Message{To: "user@example.com", Subject: "Grüße", Body: "hi"},
+			contains: []string{"Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=\r\n"},
+		},
+		{
+			name:    "Line break in recipient",
+			msg:     Message{To: "user@example.com\r\nBcc: victim@example.com", Subject: "Hello"},
+			wantErr: true,
+		},
+		{
+			name:    "Line break in subject",
+			msg:     Message{To: "user@example.com", Subject: "Hello\nBcc: victim@example.com"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Format("chirpy@example.com", tt.msg, date)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Format() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("Format() = %q, want it to contain %q", data, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOutboxMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	mailer := &OutboxMailer{Dir: dir, From: "chirpy@example.com"}
+
+	for _, subject := range []string{"First", "Second"} {
+		err := mailer.Send(context.Background(), Message{To: "user@example.com", Subject: subject, Body: "hi"})
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("outbox has %d messages, want 2 (%v)", len(files), err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "To: user@example.com\r\n") {
+		t.Errorf("message = %q", data)
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	mailer := &MemoryMailer{}
+	msg := Message{To: "user@example.com", Subject: "Hello", Body: "hi"}
+	if err := mailer.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != 1 || messages[0] != msg {
+		t.Errorf("Messages() = %v, want [%v]", messages, msg)
+	}
+}
+
+// fakeSMTPServer accepts a single plain text SMTP session and returns the
+// envelope and data it received.
+func fakeSMTPServer(t *testing.T) (addr string, received <-chan []string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var got []string
+		reader := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				lines <- got
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			got = append(got, line)
+			switch {
+			case inData:
+				if line == "." {
+					inData = false
+					reply("250 OK")
+				}
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250 localhost")
+			case line == "DATA":
+				inData = true
+				reply("354 Go ahead")
+			case line == "QUIT":
+				reply("221 Bye")
+				lines <- got
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return listener.Addr().String(), lines
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	mailer := &SMTPMailer{Addr: addr, From: "chirpy@example.com"}
+
+	err := mailer.Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Reset your password",
+		Body:    "Your token is abc\n.\nstill the body",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	session := strings.Join(<-received, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<chirpy@example.com>",
+		"RCPT TO:<user@example.com>",
+		"Subject: Reset your password",
+		"Your token is abc\n..\nstill the body",
+	} {
+		if !strings.Contains(session, want) {
+			t.Errorf("session = %q, want it to contain %q", session, want)
+		}
+	}
+}
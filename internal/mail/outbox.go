@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// OutboxMailer writes every message to a .eml file in Dir instead of
+// sending it, for development without a mail server.
+type OutboxMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *OutboxMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := Format(m.From, msg, now)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(m.Dir, 0o700)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	return os.WriteFile(filepath.Join(m.Dir, name), data, 0o600)
+}
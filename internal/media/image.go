@@ -0,0 +1,248 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxDimension caps the width and height of uploads so a small file
+	// can't decode into an enormous bitmap.
+	MaxDimension = 8000
+	// MaxPixels caps the pixels of all frames together, which is what
+	// decoding allocates memory for.
+	MaxPixels = 40_000_000
+	// MaxFrames caps the frames of an animated GIF.
+	MaxFrames     = 100
+	ThumbnailSize = 320
+	jpegQuality   = 90
+)
+
+var ErrUnsupportedType = errors.New("unsupported media type")
+
+// Image is an upload after processing. Data and Thumbnail are freshly
+// encoded, so metadata such as EXIF from the original file is gone.
+type Image struct {
+	ContentType        string
+	Extension          string
+	Width              int
+	Height             int
+	Data               []byte
+	Thumbnail          []byte
+	ThumbnailType      string
+	ThumbnailExtension string
+}
+
+// ProcessImage sniffs the type of data from its content, ignoring whatever
+// the client claimed, then re-encodes it and renders a thumbnail that fits
+// in ThumbnailSize x ThumbnailSize. JPEG, PNG and GIF are accepted.
+func ProcessImage(data []byte) (Image, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return Image{}, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+	if config.Width > MaxDimension || config.Height > MaxDimension {
+		return Image{}, fmt.Errorf("image is larger than %dx%d pixels", MaxDimension, MaxDimension)
+	}
+
+	// Check the size before decoding anything, since that is what costs
+	// the memory.
+	pixels := int64(config.Width) * int64(config.Height)
+	if contentType == "image/gif" {
+		var frames int
+		frames, pixels, err = gifFrames(data)
+		if err != nil {
+			return Image{}, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+		}
+		if frames > MaxFrames {
+			return Image{}, fmt.Errorf("animation has more than %d frames", MaxFrames)
+		}
+	}
+	if pixels > MaxPixels {
+		return Image{}, fmt.Errorf("image has more than %d pixels", MaxPixels)
+	}
+
+	result := Image{
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+	}
+
+	var first image.Image
+	var buf bytes.Buffer
+	switch contentType {
+	case "image/gif":
+		// Keep animations, but drop comments and application extensions.
+		animation, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+		}
+		first = animation.Image[0]
+		err = gif.EncodeAll(&buf, animation)
+		if err != nil {
+			return Image{}, err
+		}
+		result.Extension = ".gif"
+	case "image/png":
+		first, err = png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+		}
+		err = png.Encode(&buf, first)
+		if err != nil {
+			return Image{}, err
+		}
+		result.Extension = ".png"
+	default:
+		first, err = jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+		}
+		err = jpeg.Encode(&buf, first, &jpeg.Options{Quality: jpegQuality})
+		if err != nil {
+			return Image{}, err
+		}
+		result.Extension = ".jpg"
+	}
+	result.Data = buf.Bytes()
+
+	thumbnail := Resize(first, ThumbnailSize, ThumbnailSize)
+	var thumbBuf bytes.Buffer
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&thumbBuf, thumbnail, &jpeg.Options{Quality: jpegQuality})
+		result.ThumbnailType, result.ThumbnailExtension = "image/jpeg", ".jpg"
+	} else {
+		// PNG and GIF may be transparent, which JPEG can't represent.
+		err = png.Encode(&thumbBuf, thumbnail)
+		result.ThumbnailType, result.ThumbnailExtension = "image/png", ".png"
+	}
+	if err != nil {
+		return Image{}, err
+	}
+	result.Thumbnail = thumbBuf.Bytes()
+
+	return result, nil
+}
+
+// gifFrames walks the blocks of a GIF without decoding any image data and
+// returns the number of frames and their pixels added up.
+func gifFrames(data []byte) (int, int64, error) {
+	errTruncated := errors.New("gif: truncated")
+
+	// Header and logical screen descriptor.
+	pos := 13
+	if len(data) < pos {
+		return 0, 0, errTruncated
+	}
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+
+	// skipSubBlocks moves pos past a sequence of data sub-blocks.
+	skipSubBlocks := func() error {
+		for {
+			if pos >= len(data) {
+				return errTruncated
+			}
+			size := int(data[pos])
+			pos += 1 + size
+			if size == 0 {
+				return nil
+			}
+		}
+	}
+
+	var frames int
+	var pixels int64
+	for {
+		if pos >= len(data) {
+			return 0, 0, errTruncated
+		}
+		switch data[pos] {
+		case 0x21: // Extension: label, then sub-blocks.
+			pos += 2
+			if err := skipSubBlocks(); err != nil {
+				return 0, 0, err
+			}
+		case 0x2C: // Image descriptor.
+			if pos+10 > len(data) {
+				return 0, 0, errTruncated
+			}
+			width := int64(data[pos+5]) | int64(data[pos+6])<<8
+			height := int64(data[pos+7]) | int64(data[pos+8])<<8
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			// LZW minimum code size, then the image data.
+			pos++
+			if err := skipSubBlocks(); err != nil {
+				return 0, 0, err
+			}
+			frames++
+			pixels += width * height
+		case 0x3B: // Trailer.
+			return frames, pixels, nil
+		default:
+			return 0, 0, fmt.Errorf("gif: unknown block 0x%02x", data[pos])
+		}
+	}
+}
+
+// Resize scales src down to fit in maxWidth x maxHeight, keeping its aspect
+// ratio. Each destination pixel is the average of the source pixels it
+// covers. Images that already fit are returned unchanged.
+func Resize(src image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxWidth && height <= maxHeight {
+		return src
+	}
+
+	dstWidth, dstHeight := maxWidth, height*maxWidth/width
+	if dstHeight > maxHeight {
+		dstWidth, dstHeight = width*maxHeight/height, maxHeight
+	}
+	dstWidth, dstHeight = max(dstWidth, 1), max(dstHeight, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		y0 := bounds.Min.Y + y*height/dstHeight
+		y1 := max(bounds.Min.Y+(y+1)*height/dstHeight, y0+1)
+		for x := 0; x < dstWidth; x++ {
+			x0 := bounds.Min.X + x*width/dstWidth
+			x1 := max(bounds.Min.X+(x+1)*width/dstWidth, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,257 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// jpegWithExif encodes img as a JPEG and inserts an APP1 Exif segment right
+// after the SOI marker, the way cameras store metadata.
+func jpegWithExif(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	payload := append([]byte("Exif\x00\x00"), []byte("GPS 52.5200 N 13.4050 E")...)
+	segment := []byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	segment = append(segment, payload...)
+
+	data := buf.Bytes()
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+// gifHeaders builds a GIF with frames width x height frames whose image
+// data is empty. Only the headers are valid, which is all the size checks
+// should read.
+func gifHeaders(width, height, frames int) []byte {
+	le := func(v int) []byte { return []byte{byte(v), byte(v >> 8)} }
+
+	data := []byte("GIF89a")
+	data = append(data, le(width)...)
+	data = append(data, le(height)...)
+	data = append(data, 0x80, 0, 0, 0, 0, 0, 255, 255, 255)
+	for range frames {
+		data = append(data, 0x21, 0xF9, 4, 0, 10, 0, 0, 0)
+		data = append(data, 0x2C, 0, 0, 0, 0)
+		data = append(data, le(width)...)
+		data = append(data, le(height)...)
+		data = append(data, 0, 2, 0)
+	}
+	return append(data, 0x3B)
+}
+
+// pngHeaders encodes a small PNG and rewrites its IHDR chunk to claim
+// width x height.
+func pngHeaders(t *testing.T, width, height int) []byte {
+	t.Helper()
+	data := encodePNG(t, testImage(1, 1))
+	// Signature, chunk length and type come before the IHDR fields.
+	binary.BigEndian.PutUint32(data[16:], uint32(width))
+	binary.BigEndian.PutUint32(data[20:], uint32(height))
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestGIFFrames(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		wantFrames int
+		wantPixels int64
+		wantErr    bool
+	}{
+		{
+			name:       "Single frame",
+			data:       gifHeaders(10, 20, 1),
+			wantFrames: 1,
+			wantPixels: 200,
+		},
+		{
+			name:       "Animation",
+			data:       gifHeaders(100, 100, 30),
+			wantFrames: 30,
+			wantPixels: 300_000,
+		},
+		{
+			name:    "Missing trailer",
+			data:    gifHeaders(10, 10, 2)[:40],
+			wantErr: true,
+		},
+		{
+			name:    "Unknown block",
+			data:    append(gifHeaders(10, 10, 0)[:19], 0x99),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, pixels, err := gifFrames(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("gifFrames() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if frames != tt.wantFrames || pixels != tt.wantPixels {
+				t.Errorf("gifFrames() = %d, %d, want %d, %d", frames, pixels, tt.wantFrames, tt.wantPixels)
+			}
+		})
+	}
+}
+
+func TestProcessImage(t *testing.T) {
+	tests := []struct {
+		name            string
+		data            []byte
+		wantType        string
+		wantWidth       int
+		wantHeight      int
+		wantThumbWidth  int
+		wantThumbHeight int
+	}{
+		{
+			name:            "Small PNG",
+			data:            encodePNG(t, testImage(40, 30)),
+			wantType:        "image/png",
+			wantWidth:       40,
+			wantHeight:      30,
+			wantThumbWidth:  40,
+			wantThumbHeight: 30,
+		},
+		{
+			name:            "Wide PNG is scaled to fit",
+			data:            encodePNG(t, testImage(640, 160)),
+			wantType:        "image/png",
+			wantWidth:       640,
+			wantHeight:      160,
+			wantThumbWidth:  320,
+			wantThumbHeight: 80,
+		},
+		{
+			name:            "Tall JPEG is scaled to fit",
+			data:            jpegWithExif(t, testImage(200, 800)),
+			wantType:        "image/jpeg",
+			wantWidth:       200,
+			wantHeight:      800,
+			wantThumbWidth:  80,
+			wantThumbHeight: 320,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := ProcessImage(tt.data)
+			if err != nil {
+				t.Fatalf("ProcessImage() error = %v", err)
+			}
+			if img.ContentType != tt.wantType || img.Width != tt.wantWidth || img.Height != tt.wantHeight {
+				t.Errorf("ProcessImage() = %s %dx%d, want %s %dx%d",
+					img.ContentType, img.Width, img.Height, tt.wantType, tt.wantWidth, tt.wantHeight)
+			}
+
+			thumb, _, err := image.DecodeConfig(bytes.NewReader(img.Thumbnail))
+			if err != nil {
+				t.Fatalf("decoding thumbnail: %v", err)
+			}
+			if thumb.Width != tt.wantThumbWidth || thumb.Height != tt.wantThumbHeight {
+				t.Errorf("thumbnail is %dx%d, want %dx%d", thumb.Width, thumb.Height, tt.wantThumbWidth, tt.wantThumbHeight)
+			}
+		})
+	}
+}
+
+func TestProcessImageStripsExif(t *testing.T) {
+	data := jpegWithExif(t, testImage(64, 64))
+	if !bytes.Contains(data, []byte("Exif")) {
+		t.Fatal("test image has no Exif segment")
+	}
+
+	img, err := ProcessImage(data)
+	if err != nil {
+		t.Fatalf("ProcessImage() error = %v", err)
+	}
+	if bytes.Contains(img.Data, []byte("Exif")) || bytes.Contains(img.Data, []byte("GPS")) {
+		t.Error("ProcessImage() kept the Exif segment")
+	}
+}
+
+func TestProcessImageRejects(t *testing.T) {
+	oversized := image.NewGray(image.Rect(0, 0, MaxDimension+1, 1))
+
+	tests := []struct {
+		name        string
+		data        []byte
+		unsupported bool
+	}{
+		{
+			name:        "Plain text",
+			data:        []byte("definitely not an image"),
+			unsupported: true,
+		},
+		{
+			name:        "HTML",
+			data:        []byte("<html><script>alert(1)</script></html>"),
+			unsupported: true,
+		},
+		{
+			name:        "Truncated PNG",
+			data:        encodePNG(t, testImage(10, 10))[:20],
+			unsupported: true,
+		},
+		{
+			name: "Too wide",
+			data: encodePNG(t, oversized),
+		},
+		{
+			name: "Too many pixels",
+			data: pngHeaders(t, MaxDimension, MaxDimension),
+		},
+		{
+			name: "Too many frames",
+			data: gifHeaders(1, 1, MaxFrames+1),
+		},
+		{
+			name: "Frames add up to too many pixels",
+			data: gifHeaders(2000, 2000, 11),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ProcessImage(tt.data)
+			if err == nil {
+				t.Fatal("ProcessImage() error = nil, want an error")
+			}
+			if errors.Is(err, ErrUnsupportedType) != tt.unsupported {
+				t.Errorf("ProcessImage() error = %v, unsupported %v", err, tt.unsupported)
+			}
+		})
+	}
+}
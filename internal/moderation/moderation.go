@@ -0,0 +1,211 @@
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+type Action string
+
+const (
+	ActionAllow  Action = "allow"
+	ActionMask   Action = "mask"
+	ActionFlag   Action = "flag"
+	ActionReject Action = "reject"
+)
+
+// severity orders actions so a chain can keep the strictest one.
+var severity = map[Action]int{
+	ActionAllow:  0,
+	ActionMask:   1,
+	ActionFlag:   2,
+	ActionReject: 3,
+}
+
+func ParseAction(s string) (Action, error) {
+	action := Action(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := severity[action]; !ok || action == ActionAllow {
+		return "", fmt.Errorf("unknown moderation action %q", s)
+	}
+	return action, nil
+}
+
+const maskText = "****"
+
+// Verdict is the result of moderating a chirp. Body is the text after
+// masking, Action the strictest action any filter asked for.
+type Verdict struct {
+	Body    string
+	Action  Action
+	Reasons []string
+}
+
+func (v Verdict) escalate(action Action, reason string) Verdict {
+	if severity[action] > severity[v.Action] {
+		v.Action = action
+	}
+	v.Reasons = append(v.Reasons, reason)
+	return v
+}
+
+type Filter interface {
+	Check(v Verdict) Verdict
+}
+
+// Chain runs filters in order, each one seeing the body as masked by the
+// filters before it.
+type Chain []Filter
+
+func (c Chain) Moderate(body string) Verdict {
+	v := Verdict{Body: body, Action: ActionAllow}
+	for _, f := range c {
+		v = f.Check(v)
+	}
+	return v
+}
+
+// WordList is a set of normalized words with the action each one triggers.
+// It is safe to swap the contents while filters are reading it.
+type WordList struct {
+	mu    sync.RWMutex
+	words map[string]Action
+}
+
+func NewWordList(words map[string]Action) *WordList {
+	l := &WordList{}
+	l.Set(words)
+	return l
+}
+
+func (l *WordList) Set(words map[string]Action) {
+	normalized := make(map[string]Action, len(words))
+	for word, action := range words {
+		normalized[Normalize(word)] = action
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.words = normalized
+}
+
+func (l *WordList) Lookup(word string) (Action, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	action, ok := l.words[Normalize(word)]
+	return action, ok
+}
+
+// LoadWordListFile reads one word per line, optionally followed by an
+// action. Words without an action are masked. Blank lines and lines
+// starting with '#' are skipped.
+func LoadWordListFile(path string) (map[string]Action, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	words := map[string]Action{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		action := ActionMask
+		if len(fields) > 1 {
+			action, err = ParseAction(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+			}
+		}
+		words[fields[0]] = action
+	}
+	return words, scanner.Err()
+}
+
+// WordFilter looks up every token of the body in a WordList. Masked words
+// are replaced with asterisks, other matches only raise the action.
+type WordFilter struct {
+	List *WordList
+}
+
+func (f WordFilter) Check(v Verdict) Verdict {
+	var b strings.Builder
+	last := 0
+	for _, token := range Tokenize(v.Body) {
+		action, ok := f.List.Lookup(token.Text)
+		if !ok {
+			continue
+		}
+		v = v.escalate(action, fmt.Sprintf("word %q", Normalize(token.Text)))
+		if action == ActionMask {
+			b.WriteString(v.Body[last:token.Start])
+			b.WriteString(maskText)
+			last = token.End
+		}
+	}
+	if last == 0 {
+		return v
+	}
+	b.WriteString(v.Body[last:])
+	v.Body = b.String()
+	return v
+}
+
+// RegexFilter applies Action to bodies matching Pattern. Masking replaces
+// each match.
+type RegexFilter struct {
+	Pattern *regexp.Regexp
+	Action  Action
+}
+
+func (f RegexFilter) Check(v Verdict) Verdict {
+	if !f.Pattern.MatchString(v.Body) {
+		return v
+	}
+	v = v.escalate(f.Action, fmt.Sprintf("pattern %q", f.Pattern.String()))
+	if f.Action == ActionMask {
+		v.Body = f.Pattern.ReplaceAllString(v.Body, maskText)
+	}
+	return v
+}
+
+// LoadRegexRulesFile reads rules of the form "<action> <pattern>", one per
+// line. Blank lines and lines starting with '#' are skipped.
+func LoadRegexRulesFile(path string) ([]RegexFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []RegexFilter
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		actionString, pattern, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected \"<action> <pattern>\"", path, lineNumber)
+		}
+		action, err := ParseAction(actionString)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		re, err := regexp.Compile(strings.TrimSpace(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		rules = append(rules, RegexFilter{Pattern: re, Action: action})
+	}
+	return rules, scanner.Err()
+}
@@ -0,0 +1,128 @@
+package moderation
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestChainModerate(t *testing.T) {
+	list := NewWordList(map[string]Action{
+		"kerfuffle": ActionMask,
+		"sharbert":  ActionMask,
+		"fornax":    ActionMask,
+		"spamword":  ActionFlag,
+		"slur":      ActionReject,
+	})
+	chain := Chain{WordFilter{List: list}}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantBody   string
+		wantAction Action
+	}{
+		{
+			name:       "Clean chirp",
+			body:       "I had something interesting for breakfast",
+			wantBody:   "I had something interesting for breakfast",
+			wantAction: ActionAllow,
+		},
+		{
+			name:       "Masks regardless of case",
+			body:       "This is a Kerfuffle opinion",
+			wantBody:   "This is a **** opinion",
+			wantAction: ActionMask,
+		},
+		{
+			name:       "Masks words followed by punctuation",
+			body:       "What a kerfuffle! Sharbert, really.",
+			wantBody:   "What a ****! ****, really.",
+			wantAction: ActionMask,
+		},
+		{
+			name:       "Masks diacritics and lookalikes",
+			body:       "k3rfüffle and fоrnax",
+			wantBody:   "**** and ****",
+			wantAction: ActionMask,
+		},
+		{
+			name:       "Masks symbol substitutions",
+			body:       "sh@rbert $harbert",
+			wantBody:   "**** ****",
+			wantAction: ActionMask,
+		},
+		{
+			name:       "Leaves mentions intact",
+			body:       "@kerfuffle_fan hi",
+			wantBody:   "@kerfuffle_fan hi",
+			wantAction: ActionAllow,
+		},
+		{
+			name:       "Flags without masking",
+			body:       "buy spamword now",
+			wantBody:   "buy spamword now",
+			wantAction: ActionFlag,
+		},
+		{
+			name:       "Reject wins over mask",
+			body:       "kerfuffle slur",
+			wantBody:   "**** slur",
+			wantAction: ActionReject,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chain.Moderate(tt.body)
+			if got.Body != tt.wantBody {
+				t.Errorf("Moderate() body = %q, want %q", got.Body, tt.wantBody)
+			}
+			if got.Action != tt.wantAction {
+				t.Errorf("Moderate() action = %v, want %v", got.Action, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestRegexFilter(t *testing.T) {
+	chain := Chain{
+		RegexFilter{Pattern: regexp.MustCompile(`(?i)free\s+money`), Action: ActionFlag},
+		RegexFilter{Pattern: regexp.MustCompile(`\d{3}-\d{4}`), Action: ActionMask},
+	}
+
+	got := chain.Moderate("FREE money, call 555-1234")
+	if got.Body != "FREE money, call ****" {
+		t.Errorf("Moderate() body = %q", got.Body)
+	}
+	if got.Action != ActionFlag {
+		t.Errorf("Moderate() action = %v, want %v", got.Action, ActionFlag)
+	}
+	if len(got.Reasons) != 2 {
+		t.Errorf("Moderate() reasons = %v, want 2 entries", got.Reasons)
+	}
+}
+
+func TestLoadWordListFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	content := "# comment\nkerfuffle\n\nspamword flag\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	words, err := LoadWordListFile(path)
+	if err != nil {
+		t.Fatalf("LoadWordListFile() error = %v", err)
+	}
+	if words["kerfuffle"] != ActionMask || words["spamword"] != ActionFlag || len(words) != 2 {
+		t.Errorf("LoadWordListFile() = %v", words)
+	}
+
+	if err := os.WriteFile(path, []byte("word explode\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadWordListFile(path); err == nil {
+		t.Errorf("LoadWordListFile() expected error for unknown action")
+	}
+}
@@ -0,0 +1,92 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Token is a word in a chirp body. Start and End are byte offsets into the
+// body, so the original text can be replaced in place.
+type Token struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// Tokenize splits body into words, dropping surrounding punctuation so
+// "kerfuffle!" yields "kerfuffle". Symbols commonly used in place of
+// letters are kept inside words: "sh@rbert" and "$harbert" stay whole,
+// while a leading '@' is left alone so mentions aren't mangled.
+func Tokenize(body string) []Token {
+	var tokens []Token
+	start := -1
+
+	for i, r := range body {
+		if isWordRune(r) || (start >= 0 && r == '@') {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, Token{Text: body[start:i], Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, Token{Text: body[start:], Start: start, End: len(body)})
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return r == '$' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// Normalize folds a word to the form stored in word lists: lower case,
+// without diacritics, with lookalike characters mapped to the Latin
+// letters they imitate.
+func Normalize(word string) string {
+	var b strings.Builder
+	b.Grow(len(word))
+	for _, r := range strings.ToLower(word) {
+		if unicode.Is(unicode.Mn, r) {
+			// Combining marks left over from decomposed input.
+			continue
+		}
+		if folded, ok := foldTable[r]; ok {
+			r = folded
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// foldTable maps accented letters, homoglyphs and leetspeak to ASCII.
+var foldTable = map[rune]rune{
+	// Latin letters with diacritics.
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a', 'ā': 'a', 'ă': 'a', 'ą': 'a',
+	'ç': 'c', 'ć': 'c', 'č': 'c',
+	'ď': 'd', 'đ': 'd',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e', 'ē': 'e', 'ė': 'e', 'ę': 'e', 'ě': 'e',
+	'ğ': 'g',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i', 'ī': 'i', 'į': 'i', 'ı': 'i',
+	'ł': 'l', 'ľ': 'l',
+	'ñ': 'n', 'ń': 'n', 'ň': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ø': 'o', 'ō': 'o', 'ő': 'o',
+	'ř': 'r',
+	'ś': 's', 'š': 's', 'ş': 's',
+	'ť': 't', 'ţ': 't',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u', 'ū': 'u', 'ů': 'u', 'ű': 'u',
+	'ý': 'y', 'ÿ': 'y',
+	'ź': 'z', 'ż': 'z', 'ž': 'z',
+
+	// Cyrillic and Greek lookalikes.
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's',
+	'α': 'a', 'β': 'b', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p',
+	'τ': 't', 'υ': 'u', 'χ': 'x',
+
+	// Leetspeak.
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '@': 'a', '$': 's',
+}
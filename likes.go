@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+// attachLikes fills in the like count of every chirp in place. LikedByMe is
+// only set when the request came from a signed in viewer.
+func (cfg *apiConfig) attachLikes(ctx context.Context, chirps []chirpResponse, viewerID uuid.NullUUID) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.Id)
+	}
+
+	stats, err := cfg.dbQueries.GetChirpLikeStats(ctx, database.GetChirpLikeStatsParams{
+		ViewerID: viewerID,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		return err
+	}
+
+	statsByChirp := make(map[uuid.UUID]database.GetChirpLikeStatsRow, len(stats))
+	for _, stat := range stats {
+		statsByChirp[stat.ChirpID] = stat
+	}
+
+	for i := range chirps {
+		stat := statsByChirp[chirps[i].Id]
+		chirps[i].LikeCount = stat.LikeCount
+		if viewerID.Valid {
+			likedByMe := stat.LikedByMe
+			chirps[i].LikedByMe = &likedByMe
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Could not get chirp", err)
+		return
+	}
+
+	blocked, err := cfg.isBlockedBy(r.Context(), chirp.UserID, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while liking chirp", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, blockedMessage, nil)
+		return
+	}
+
+	err = cfg.dbQueries.LikeChirp(r.Context(), database.LikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while liking chirp", err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	err = cfg.dbQueries.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while unliking chirp", err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetUserLikes(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	v := cfg.viewer(r)
+	rows, err := cfg.dbQueries.ListLikedChirps(r.Context(), database.ListLikedChirpsParams{
+		UserID:            userID,
+		ViewerID:          v.ID,
+		ViewerIsModerator: v.Moderator,
+		CursorCreatedAt:   page.cursorCreatedAt(),
+		CursorID:          page.cursorID(),
+		PageLimit:         page.queryLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting liked chirps", err)
+		return
+	}
+
+	rows, nextCursor := trimPage(rows, page.Limit, func(row database.ListLikedChirpsRow) pageCursor {
+		return pageCursor{CreatedAt: row.LikedAt, ID: row.Chirp.ID}
+	})
+
+	response := make([]chirpResponse, 0, len(rows))
+	for _, row := range rows {
+		response = append(response, newChirpResponse(row.Chirp))
+	}
+
+	err = cfg.decorateChirps(r.Context(), response, v)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting chirp details", err)
+		return
+	}
+
+	setNextPageHeaders(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, response)
+}
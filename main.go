@@ -1,12 +1,23 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/alexanderarrr/chirpy-http-server/internal/auth"
 	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/alexanderarrr/chirpy-http-server/internal/mail"
+	"github.com/alexanderarrr/chirpy-http-server/internal/media"
+	"github.com/alexanderarrr/chirpy-http-server/internal/moderation"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
@@ -22,31 +33,159 @@ func main() {
 		log.Fatalf("Error while accessing database: %v", err)
 		return
 	}
+
+	// Commands only need the database, so they run before the server's
+	// setup generates keys or creates directories.
+	if len(os.Args) > 1 {
+		err = runCommand(context.Background(), db, database.New(db), os.Args[1:])
+		if err != nil {
+			log.Fatalf("Error while running %s: %v", os.Args[1], err)
+		}
+		return
+	}
+
 	platform := os.Getenv("PLATFORM")
-	tokenSecret := os.Getenv("TOKEN_SECRET")
 	polkaKey := os.Getenv("POLKA_KEY")
 
+	moderationWords := moderation.NewWordList(nil)
+	moderator := moderation.Chain{moderation.WordFilter{List: moderationWords}}
+	if rulesFile := os.Getenv("MODERATION_RULES_FILE"); rulesFile != "" {
+		rules, err := moderation.LoadRegexRulesFile(rulesFile)
+		if err != nil {
+			log.Fatalf("Error while loading moderation rules: %v", err)
+		}
+		for _, rule := range rules {
+			moderator = append(moderator, rule)
+		}
+	}
+
+	keys, err := newKeyring()
+	if err != nil {
+		log.Fatalf("Error while loading signing keys: %v", err)
+	}
+
+	blobs, err := newBlobStore()
+	if err != nil {
+		log.Fatalf("Error while setting up media storage: %v", err)
+	}
+
+	mailer, err := newMailer()
+	if err != nil {
+		log.Fatalf("Error while setting up mail delivery: %v", err)
+	}
+
+	deletionGracePeriod, err := durationEnv("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour)
+	if err != nil {
+		log.Fatal(err)
+	}
+	purgeInterval, err := durationEnv("ACCOUNT_PURGE_INTERVAL", time.Hour)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// A grace period of 0 purges right away, but the purger can't tick
+	// every 0s.
+	if purgeInterval <= 0 {
+		log.Fatalf("ACCOUNT_PURGE_INTERVAL must be longer than 0, got %s", purgeInterval)
+	}
+
 	apiCfg := &apiConfig{
-		dbQueries:   *database.New(db),
-		platform:    platform,
-		tokenSecret: tokenSecret,
-		polkaKey:    polkaKey,
+		db:                   db,
+		dbQueries:            *database.New(db),
+		platform:             platform,
+		keys:                 keys,
+		polkaKey:             polkaKey,
+		moderator:            moderator,
+		moderationWords:      moderationWords,
+		moderationWordsFile:  os.Getenv("MODERATION_WORDS_FILE"),
+		blobs:                blobs,
+		mailer:               mailer,
+		publicURL:            cmp.Or(os.Getenv("PUBLIC_URL"), "http://localhost:"+port),
+		requireVerifiedEmail: os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true",
+		deletionGracePeriod:  deletionGracePeriod,
+	}
+
+	err = apiCfg.reloadModerationWords(context.Background())
+	if err != nil {
+		log.Fatalf("Error while loading moderation words: %v", err)
 	}
 
+	go apiCfg.runDeletionPurger(context.Background(), purgeInterval)
+
 	srvMux := http.NewServeMux()
-	srvMux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))))
+	srvMux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app", appFileServer(filepathRoot))))
+	if local, ok := blobs.(*media.LocalStore); ok {
+		srvMux.Handle("GET "+local.BaseURL+"/", http.StripPrefix(local.BaseURL, mediaFileServer(local.Dir)))
+	}
 	srvMux.HandleFunc("GET /api/healthz", handlerReadiness)
-	srvMux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
-	srvMux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
+	srvMux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handlerJWKS)
+	srvMux.Handle("GET /admin/metrics", apiCfg.requireRole(roleAdmin, apiCfg.handlerMetrics))
+	srvMux.Handle("POST /admin/reset", apiCfg.requireRole(roleAdmin, apiCfg.handlerReset))
+	srvMux.Handle("PUT /admin/users/{userID}/roles/{role}", apiCfg.requireRole(roleAdmin, apiCfg.handlerGrantRole))
+	srvMux.Handle("DELETE /admin/users/{userID}/roles/{role}", apiCfg.requireRole(roleAdmin, apiCfg.handlerRevokeRole))
+	srvMux.Handle("GET /admin/users/suspended", apiCfg.requireRole(roleModerator, apiCfg.handlerListSuspendedUsers))
+	srvMux.Handle("PUT /admin/users/{userID}/suspension", apiCfg.requireRole(roleModerator, apiCfg.handlerSuspendUser))
+	srvMux.Handle("DELETE /admin/users/{userID}/suspension", apiCfg.requireRole(roleModerator, apiCfg.handlerUnsuspendUser))
+	srvMux.Handle("GET /admin/moderation/words", apiCfg.requireRole(roleAdmin, apiCfg.handlerListModerationWords))
+	srvMux.Handle("PUT /admin/moderation/words/{word}", apiCfg.requireRole(roleAdmin, apiCfg.handlerPutModerationWord))
+	srvMux.Handle("DELETE /admin/moderation/words/{word}", apiCfg.requireRole(roleAdmin, apiCfg.handlerDeleteModerationWord))
+	srvMux.Handle("GET /admin/moderation/reports", apiCfg.requireRole(roleModerator, apiCfg.handlerListOpenReports))
+	srvMux.Handle("GET /admin/moderation/flags", apiCfg.requireRole(roleModerator, apiCfg.handlerListOpenFlags))
+	srvMux.Handle("GET /admin/moderation/chirps/{chirpID}", apiCfg.requireRole(roleModerator, apiCfg.handlerGetModerationContext))
+	srvMux.Handle("POST /admin/moderation/chirps/{chirpID}/actions", apiCfg.requireRole(roleModerator, apiCfg.handlerModerateChirp))
 	srvMux.HandleFunc("POST /api/users", apiCfg.handlerCreateUser)
 	srvMux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser)
+	srvMux.Handle("PATCH /api/users/me", apiCfg.requireScope(scopeProfileWrite, apiCfg.handlerPatchMe))
+	srvMux.HandleFunc("DELETE /api/users/me", apiCfg.handlerDeleteMe)
+	srvMux.HandleFunc("GET /api/users/me/export", apiCfg.handlerExportMe)
+	srvMux.HandleFunc("GET /api/users/me/2fa", apiCfg.handlerGetTwoFactor)
+	srvMux.HandleFunc("POST /api/users/me/2fa/enroll", apiCfg.handlerEnrollTwoFactor)
+	srvMux.HandleFunc("POST /api/users/me/2fa/confirm", apiCfg.handlerConfirmTwoFactor)
+	srvMux.HandleFunc("POST /api/users/me/2fa/recovery-codes", apiCfg.handlerRegenerateRecoveryCodes)
+	srvMux.HandleFunc("DELETE /api/users/me/2fa", apiCfg.handlerDisableTwoFactor)
+	srvMux.HandleFunc("POST /api/users/email/confirm", apiCfg.handlerConfirmEmailChange)
+	srvMux.HandleFunc("GET /api/users/verify", apiCfg.handlerVerifyEmail)
+	srvMux.HandleFunc("POST /api/users/verify", apiCfg.handlerVerifyEmail)
+	srvMux.HandleFunc("POST /api/users/verify/resend", apiCfg.handlerResendVerification)
+	srvMux.Handle("GET /api/users/me/mentions", apiCfg.requireScope(scopeChirpsRead, apiCfg.handlerGetMyMentions))
+	srvMux.Handle("GET /api/users/me/blocks", apiCfg.requireScope(scopeProfileRead, apiCfg.handlerGetMyBlocks))
+	srvMux.Handle("GET /api/users/me/mutes", apiCfg.requireScope(scopeProfileRead, apiCfg.handlerGetMyMutes))
+	srvMux.Handle("POST /api/users/{userID}/follow", apiCfg.requireScope(scopeSocialWrite, apiCfg.handlerFollowUser))
+	srvMux.Handle("DELETE /api/users/{userID}/follow", apiCfg.requireScope(scopeSocialWrite, apiCfg.handlerUnfollowUser))
+	srvMux.Handle("GET /api/users/{userID}", apiCfg.requireScope(scopeProfileRead, apiCfg.handlerGetUserProfile))
+	srvMux.Handle("POST /api/users/{userID}/block", apiCfg.requireScope(scopeSocialWrite, apiCfg.handlerBlockUser))
+	srvMux.Handle("DELETE /api/users/{userID}/block", apiCfg.requireScope(scopeSocialWrite, apiCfg.handlerUnblockUser))
+	srvMux.Handle("POST /api/users/{userID}/mute", apiCfg.requireScope(scopeSocialWrite, apiCfg.handlerMuteUser))
+	srvMux.Handle("DELETE /api/users/{userID}/mute", apiCfg.requireScope(scopeSocialWrite, apiCfg.handlerUnmuteUser))
+	srvMux.Handle("GET /api/users/{userID}/followers", apiCfg.requireScope(scopeProfileRead, apiCfg.handlerGetFollowers))
+	srvMux.Handle("GET /api/users/{userID}/following", apiCfg.requireScope(scopeProfileRead, apiCfg.handlerGetFollowing))
+	srvMux.Handle("GET /api/users/{userID}/likes", apiCfg.requireScope(scopeChirpsRead, apiCfg.handlerGetUserLikes))
 	srvMux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
+	srvMux.HandleFunc("POST /api/login/2fa", apiCfg.handlerLoginTwoFactor)
+	srvMux.HandleFunc("POST /api/password/forgot", apiCfg.handlerForgotPassword)
+	srvMux.HandleFunc("POST /api/password/reset", apiCfg.handlerResetPassword)
 	srvMux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
 	srvMux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
-	srvMux.HandleFunc("POST /api/chirps", apiCfg.handlerCreateChirp)
-	srvMux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps)
-	srvMux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirp)
-	srvMux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp)
+	srvMux.HandleFunc("GET /api/sessions", apiCfg.handlerGetSessions)
+	srvMux.HandleFunc("DELETE /api/sessions/{sessionID}", apiCfg.handlerDeleteSession)
+	srvMux.HandleFunc("POST /api/sessions/revoke-all", apiCfg.handlerRevokeOtherSessions)
+	srvMux.HandleFunc("POST /api/tokens", apiCfg.handlerCreateAccessToken)
+	srvMux.HandleFunc("GET /api/tokens", apiCfg.handlerListAccessTokens)
+	srvMux.HandleFunc("DELETE /api/tokens/{tokenID}", apiCfg.handlerRevokeAccessToken)
+	srvMux.Handle("POST /api/media", apiCfg.requireScope(scopeMediaWrite, apiCfg.handlerUploadMedia))
+	srvMux.Handle("POST /api/chirps", apiCfg.requireScope(scopeChirpsWrite, apiCfg.handlerCreateChirp))
+	srvMux.Handle("GET /api/chirps", apiCfg.requireScope(scopeChirpsRead, apiCfg.handlerGetChirps))
+	srvMux.Handle("GET /api/chirps/search", apiCfg.requireScope(scopeChirpsRead, apiCfg.handlerSearchChirps))
+	srvMux.Handle("GET /api/chirps/{chirpID}", apiCfg.requireScope(scopeChirpsRead, apiCfg.handlerGetChirp))
+	srvMux.Handle("PUT /api/chirps/{chirpID}", apiCfg.requireScope(scopeChirpsWrite, apiCfg.handlerUpdateChirp))
+	srvMux.Handle("DELETE /api/chirps/{chirpID}", apiCfg.requireScope(scopeChirpsWrite, apiCfg.handlerDeleteChirp))
+	srvMux.Handle("GET /api/chirps/{chirpID}/revisions", apiCfg.requireScope(scopeChirpsRead, apiCfg.handlerGetChirpRevisions))
+	srvMux.Handle("GET /api/chirps/{chirpID}/thread", apiCfg.requireScope(scopeChirpsRead, apiCfg.handlerGetChirpThread))
+	srvMux.Handle("POST /api/chirps/{chirpID}/likes", apiCfg.requireScope(scopeChirpsWrite, apiCfg.handlerLikeChirp))
+	srvMux.Handle("DELETE /api/chirps/{chirpID}/likes", apiCfg.requireScope(scopeChirpsWrite, apiCfg.handlerUnlikeChirp))
+	srvMux.Handle("POST /api/chirps/{chirpID}/reports", apiCfg.requireScope(scopeChirpsWrite, apiCfg.handlerCreateReport))
+	srvMux.Handle("GET /api/tags/trending", apiCfg.requireScope(scopeChirpsRead, apiCfg.handlerGetTrendingTags))
+	srvMux.Handle("GET /api/tags/{tag}/chirps", apiCfg.requireScope(scopeChirpsRead, apiCfg.handlerGetTagChirps))
+	srvMux.Handle("GET /api/timeline", apiCfg.requireScope(scopeChirpsRead, apiCfg.handlerGetTimeline))
 	srvMux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerWebhook)
 
 	srv := &http.Server{
@@ -58,6 +197,135 @@ func main() {
 	log.Fatal(srv.ListenAndServe())
 }
 
+// newBlobStore picks where uploads are kept from MEDIA_STORE: "local" (the
+// default) keeps them in MEDIA_DIR and serves them under MEDIA_BASE_URL,
+// "s3" keeps them in an S3 compatible bucket.
+func newBlobStore() (media.BlobStore, error) {
+	switch store := os.Getenv("MEDIA_STORE"); store {
+	case "", "local":
+		dir := cmp.Or(os.Getenv("MEDIA_DIR"), "./media")
+		baseURL := strings.TrimSuffix(cmp.Or(os.Getenv("MEDIA_BASE_URL"), "/media"), "/")
+		if !strings.HasPrefix(baseURL, "/") {
+			return nil, fmt.Errorf("MEDIA_BASE_URL must be a path starting with /")
+		}
+		return media.NewLocalStore(dir, baseURL)
+	case "s3":
+		s3 := &media.S3Store{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          cmp.Or(os.Getenv("S3_REGION"), "us-east-1"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PublicURL:       os.Getenv("S3_PUBLIC_URL"),
+		}
+		if s3.Endpoint == "" || s3.Bucket == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT and S3_BUCKET must be set")
+		}
+		return s3, nil
+	default:
+		return nil, fmt.Errorf("unknown MEDIA_STORE %q", store)
+	}
+}
+
+// newKeyring loads the key access tokens are signed with from
+// JWT_SIGNING_KEY_FILE, generating one for JWT_SIGNING_ALGORITHM (EdDSA or
+// RS256) on first start. The file defaults to chirpy/jwt-signing-key.pem in
+// the user's configuration directory, away from the files the server
+// hands out. To rotate, move the old file's path into the
+// comma separated JWT_PREVIOUS_KEY_FILES and point JWT_SIGNING_KEY_FILE at a
+// new one; old keys can go once the last tokens they signed have expired.
+// TOKEN_SECRET, if still set, verifies HS256 tokens from before signing
+// keys were introduced.
+func newKeyring() (*auth.Keyring, error) {
+	path := os.Getenv("JWT_SIGNING_KEY_FILE")
+	if path == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, fmt.Errorf("JWT_SIGNING_KEY_FILE must be set: %w", err)
+		}
+		path = filepath.Join(configDir, "chirpy", "jwt-signing-key.pem")
+	}
+	algorithm := cmp.Or(os.Getenv("JWT_SIGNING_ALGORITHM"), auth.AlgorithmEdDSA)
+	active, generated, err := auth.LoadOrGenerateKeyFile(path, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if generated {
+		log.Printf("Generated %s signing key %s in %s", active.Algorithm(), active.ID, path)
+	}
+
+	var previous []*auth.Key
+	for _, file := range strings.Split(os.Getenv("JWT_PREVIOUS_KEY_FILES"), ",") {
+		file = strings.TrimSpace(file)
+		if file == "" {
+			continue
+		}
+		key, err := auth.LoadKeyFile(file)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+	if secret := os.Getenv("TOKEN_SECRET"); secret != "" {
+		previous = append(previous, auth.NewHMACKey(secret))
+	}
+
+	return auth.NewKeyring(active, previous...)
+}
+
+// durationEnv reads a duration such as "720h" from the environment.
+func durationEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative duration like 720h, got %q", name, value)
+	}
+	return duration, nil
+}
+
+// newMailer picks how mail is delivered from MAIL_DELIVERY, which has to be
+// set: "smtp" sends messages through the relay at SMTP_ADDR, "outbox" is
+// for development and writes them to MAIL_OUTBOX_DIR, by default
+// chirpy/outbox in the user's cache directory. Messages carry password
+// reset and verification tokens, so the outbox must not be served.
+func newMailer() (mail.Mailer, error) {
+	from := cmp.Or(os.Getenv("MAIL_FROM"), "Chirpy <no-reply@localhost>")
+	switch delivery := os.Getenv("MAIL_DELIVERY"); delivery {
+	case "":
+		return nil, errors.New("MAIL_DELIVERY must be set to smtp, or to outbox for development")
+	case "outbox":
+		dir := os.Getenv("MAIL_OUTBOX_DIR")
+		if dir == "" {
+			cacheDir, err := os.UserCacheDir()
+			if err != nil {
+				return nil, fmt.Errorf("MAIL_OUTBOX_DIR must be set: %w", err)
+			}
+			dir = filepath.Join(cacheDir, "chirpy", "outbox")
+		}
+		log.Printf("Writing mail to %s instead of sending it, use MAIL_DELIVERY=smtp in production", dir)
+		return &mail.OutboxMailer{
+			Dir:  dir,
+			From: from,
+		}, nil
+	case "smtp":
+		smtp := &mail.SMTPMailer{
+			Addr:     os.Getenv("SMTP_ADDR"),
+			From:     from,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}
+		if smtp.Addr == "" {
+			return nil, fmt.Errorf("SMTP_ADDR must be set")
+		}
+		return smtp, nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DELIVERY %q", delivery)
+	}
+}
+
 func handlerReadiness(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
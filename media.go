@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/alexanderarrr/chirpy-http-server/internal/media"
+	"github.com/google/uuid"
+)
+
+const (
+	maxUploadBytes     = 5 << 20
+	maxMediaPerChirp   = 4
+	mediaUploadField   = "file"
+	mediaKeyPrefix     = "media/"
+	maxUploadBodyBytes = maxUploadBytes + 64<<10 // room for multipart framing
+)
+
+type mediaResponse struct {
+	Id           uuid.UUID `json:"id"`
+	Created_at   time.Time `json:"created_at"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	SizeBytes    int64     `json:"size_bytes"`
+}
+
+func (cfg *apiConfig) newMediaResponse(file database.MediaFile) mediaResponse {
+	return mediaResponse{
+		Id:           file.ID,
+		Created_at:   file.CreatedAt,
+		URL:          cfg.blobs.URL(file.BlobKey),
+		ThumbnailURL: cfg.blobs.URL(file.ThumbnailKey),
+		ContentType:  file.ContentType,
+		Width:        file.Width,
+		Height:       file.Height,
+		SizeBytes:    file.SizeBytes,
+	}
+}
+
+// mediaFileServer serves a LocalStore's directory without listing it.
+func mediaFileServer(dir string) http.Handler {
+	fileServer := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" || r.URL.Path[len(r.URL.Path)-1] == '/' {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		fileServer.ServeHTTP(w, r)
+	})
+}
+
+// readUploadFile returns the contents of the "file" part of a multipart
+// request. Parts are streamed, so only the file itself is held in memory,
+// and reading stops once it exceeds maxUploadBytes.
+func readUploadFile(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBodyBytes)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("missing %q field", mediaUploadField)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() != mediaUploadField {
+			part.Close()
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, maxUploadBytes+1))
+		part.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxUploadBytes {
+			return nil, &http.MaxBytesError{Limit: maxUploadBytes}
+		}
+		return data, nil
+	}
+}
+
+func (cfg *apiConfig) handlerUploadMedia(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	data, err := readUploadFile(w, r)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Files can be at most %d MB", maxUploadBytes>>20), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read upload", err)
+		return
+	}
+
+	img, err := media.ProcessImage(data)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Only JPEG, PNG and GIF images are supported", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	id := uuid.New()
+	blobKey := path.Join(mediaKeyPrefix, id.String()+img.Extension)
+	thumbnailKey := path.Join(mediaKeyPrefix, id.String()+"_thumb"+img.ThumbnailExtension)
+
+	err = cfg.blobs.Put(r.Context(), blobKey, img.Data, img.ContentType)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while storing upload", err)
+		return
+	}
+	err = cfg.blobs.Put(r.Context(), thumbnailKey, img.Thumbnail, img.ThumbnailType)
+	if err != nil {
+		cfg.blobs.Delete(r.Context(), blobKey)
+		respondWithError(w, http.StatusInternalServerError, "Error while storing upload", err)
+		return
+	}
+
+	file, err := cfg.dbQueries.CreateMediaFile(r.Context(), database.CreateMediaFileParams{
+		ID:           id,
+		UserID:       userID,
+		ContentType:  img.ContentType,
+		Width:        int32(img.Width),
+		Height:       int32(img.Height),
+		SizeBytes:    int64(len(img.Data)),
+		BlobKey:      blobKey,
+		ThumbnailKey: thumbnailKey,
+	})
+	if err != nil {
+		cfg.blobs.Delete(r.Context(), blobKey)
+		cfg.blobs.Delete(r.Context(), thumbnailKey)
+		respondWithError(w, http.StatusInternalServerError, "Error while saving upload", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, cfg.newMediaResponse(file))
+}
+
+// getOwnMedia looks up the given uploads and checks they all belong to
+// userID, keeping the order of ids.
+func (cfg *apiConfig) getOwnMedia(ctx context.Context, ids []uuid.UUID, userID uuid.UUID) ([]database.MediaFile, error) {
+	files, err := cfg.dbQueries.GetMediaFilesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]database.MediaFile, len(files))
+	for _, file := range files {
+		if file.UserID == userID {
+			byID[file.ID] = file
+		}
+	}
+
+	ordered := make([]database.MediaFile, 0, len(ids))
+	for _, id := range ids {
+		file, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("media %s not found", id)
+		}
+		ordered = append(ordered, file)
+	}
+	return ordered, nil
+}
+
+// parseMediaIDs checks the media_ids of a new chirp for count and duplicates.
+func parseMediaIDs(ids []uuid.UUID) error {
+	if len(ids) > maxMediaPerChirp {
+		return fmt.Errorf("a chirp can have at most %d media attachments", maxMediaPerChirp)
+	}
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return fmt.Errorf("media %s is attached more than once", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// attachMedia fills in the media attached to every chirp in place.
+func (cfg *apiConfig) attachMedia(ctx context.Context, chirps []chirpResponse) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.Id)
+	}
+
+	rows, err := cfg.dbQueries.GetChirpMedia(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	byChirp := make(map[uuid.UUID][]mediaResponse)
+	for _, row := range rows {
+		byChirp[row.ChirpID] = append(byChirp[row.ChirpID], cfg.newMediaResponse(row.MediaFile))
+	}
+
+	for i := range chirps {
+		chirps[i].Media = byChirp[chirps[i].Id]
+		if chirps[i].Media == nil {
+			chirps[i].Media = []mediaResponse{}
+		}
+	}
+	return nil
+}
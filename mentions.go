@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	minHandleLength = 3
+	maxHandleLength = 30
+)
+
+var handlePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// normalizeHandle lower cases a handle and validates it. Handles are 3-30
+// characters of letters, digits and underscores.
+func normalizeHandle(handle string) (string, error) {
+	handle = strings.ToLower(strings.TrimPrefix(handle, "@"))
+	if len(handle) < minHandleLength || len(handle) > maxHandleLength {
+		return "", errors.New("handle must be between 3 and 30 characters long")
+	}
+	if !handlePattern.MatchString(handle) {
+		return "", errors.New("handle may only contain letters, digits and underscores")
+	}
+	return handle, nil
+}
+
+type mentionToken struct {
+	Handle string
+	Offset int
+}
+
+// extractMentions finds @handle tokens in body. Offsets count runes and
+// point at the '@'. The '@' has to start a word so email addresses are not
+// picked up.
+func extractMentions(body string) []mentionToken {
+	var mentions []mentionToken
+
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '@' || (i > 0 && isTagRune(runes[i-1])) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isTagRune(runes[end]) {
+			end++
+		}
+
+		handle, err := normalizeHandle(string(runes[i+1 : end]))
+		if err == nil {
+			mentions = append(mentions, mentionToken{Handle: handle, Offset: i})
+		}
+		i = end - 1
+	}
+	return mentions
+}
+
+// saveChirpMentions replaces the mentions of a chirp with the ones found in
+// its body. Handles that don't belong to anyone, or belong to someone who
+// blocked the author, stay plain text.
+func saveChirpMentions(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	err := q.DeleteChirpMentions(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+
+	tokens := extractMentions(chirp.Body)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	handles := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		handles = append(handles, token.Handle)
+	}
+
+	users, err := q.GetUsersByHandles(ctx, database.GetUsersByHandlesParams{
+		Handles:  handles,
+		AuthorID: chirp.UserID,
+	})
+	if err != nil {
+		return err
+	}
+
+	userIDs := make(map[string]uuid.UUID, len(users))
+	for _, user := range users {
+		userIDs[user.Handle.String] = user.ID
+	}
+
+	for _, token := range tokens {
+		userID, ok := userIDs[token.Handle]
+		if !ok {
+			continue
+		}
+		err = q.CreateMention(ctx, database.CreateMentionParams{
+			ChirpID:     chirp.ID,
+			UserID:      userID,
+			StartOffset: int32(token.Offset),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type mentionResponse struct {
+	User_id uuid.UUID `json:"user_id"`
+	Handle  string    `json:"handle"`
+	Offset  int32     `json:"offset"`
+}
+
+// attachMentions fills in the resolved mentions of every chirp in place.
+func (cfg *apiConfig) attachMentions(ctx context.Context, chirps []chirpResponse) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.Id)
+	}
+
+	mentions, err := cfg.dbQueries.GetChirpMentions(ctx, chirpIDs)
+	if err != nil {
+		return err
+	}
+
+	byChirp := make(map[uuid.UUID][]mentionResponse, len(chirps))
+	for _, mention := range mentions {
+		byChirp[mention.ChirpID] = append(byChirp[mention.ChirpID], mentionResponse{
+			User_id: mention.UserID,
+			Handle:  mention.Handle.String,
+			Offset:  mention.StartOffset,
+		})
+	}
+
+	for i := range chirps {
+		chirps[i].Mentions = byChirp[chirps[i].Id]
+		if chirps[i].Mentions == nil {
+			chirps[i].Mentions = []mentionResponse{}
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerGetMyMentions(w http.ResponseWriter, r *http.Request) {
+	v, err := cfg.authenticateViewer(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	chirps, err := cfg.dbQueries.ListMentionChirps(r.Context(), database.ListMentionChirpsParams{
+		UserID:            v.ID.UUID,
+		ViewerIsModerator: v.Moderator,
+		CursorCreatedAt:   page.cursorCreatedAt(),
+		CursorID:          page.cursorID(),
+		PageLimit:         page.queryLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting mentions", err)
+		return
+	}
+
+	chirps, nextCursor := trimPage(chirps, page.Limit, chirpCursor)
+
+	response := newChirpResponses(chirps)
+	err = cfg.decorateChirps(r.Context(), response, v)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting chirp details", err)
+		return
+	}
+
+	setNextPageHeaders(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// parseHandleParam validates an optional handle from a request body.
+func parseHandleParam(handle string) (sql.NullString, error) {
+	if handle == "" {
+		return sql.NullString{}, nil
+	}
+	normalized, err := normalizeHandle(handle)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: normalized, Valid: true}, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeHandle(t *testing.T) {
+	tests := []struct {
+		name    string
+		handle  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "Lower cased",
+			handle: "Alice",
+			want:   "alice",
+		},
+		{
+			name:   "Leading at sign",
+			handle: "@bob_1",
+			want:   "bob_1",
+		},
+		{
+			name:   "Shortest handle",
+			handle: "abc",
+			want:   "abc",
+		},
+		{
+			name:   "Longest handle",
+			handle: strings.Repeat("a", maxHandleLength),
+			want:   strings.Repeat("a", maxHandleLength),
+		},
+		{
+			name:    "Too short",
+			handle:  "ab",
+			wantErr: true,
+		},
+		{
+			name:    "Too long",
+			handle:  strings.Repeat("a", maxHandleLength+1),
+			wantErr: true,
+		},
+		{
+			name:    "Empty",
+			handle:  "",
+			wantErr: true,
+		},
+		{
+			name:    "Punctuation",
+			handle:  "bad-handle",
+			wantErr: true,
+		},
+		{
+			name:    "Non ASCII letters",
+			handle:  "jöhn",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeHandle(tt.handle)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeHandle(%q) error = %v, wantErr %v", tt.handle, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeHandle(%q) = %q, want %q", tt.handle, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []mentionToken
+	}{
+		{
+			name: "Single mention",
+			body: "hi @alice",
+			want: []mentionToken{{Handle: "alice", Offset: 3}},
+		},
+		{
+			name: "Email address",
+			body: "mail a@b or bob@example.com",
+			want: nil,
+		},
+		{
+			name: "Trailing punctuation",
+			body: "@alice, @bob! (@carol)",
+			want: []mentionToken{
+				{Handle: "alice", Offset: 0},
+				{Handle: "bob", Offset: 8},
+				{Handle: "carol", Offset: 15},
+			},
+		},
+		{
+			name: "Case is folded",
+			body: "@Alice",
+			want: []mentionToken{{Handle: "alice", Offset: 0}},
+		},
+		{
+			name: "Duplicates keep every offset",
+			body: "@alice @ALICE",
+			want: []mentionToken{
+				{Handle: "alice", Offset: 0},
+				{Handle: "alice", Offset: 7},
+			},
+		},
+		{
+			name: "Offsets count runes",
+			body: "héllo @bob",
+			want: []mentionToken{{Handle: "bob", Offset: 6}},
+		},
+		{
+			name: "Double at sign",
+			body: "@@alice",
+			want: []mentionToken{{Handle: "alice", Offset: 1}},
+		},
+		{
+			name: "Invalid handles",
+			body: "@ab @jöhn @" + strings.Repeat("a", maxHandleLength+1),
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractMentions(tt.body); !slices.Equal(got, tt.want) {
+				t.Errorf("extractMentions(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/alexanderarrr/chirpy-http-server/internal/moderation"
+	"github.com/google/uuid"
+)
+
+const maxChirpLength = 140
+
+var (
+	errChirpTooLong  = errors.New("chirp is too long")
+	errChirpRejected = errors.New("chirp was rejected by moderation")
+)
+
+// moderateChirpBody checks the length of a chirp body and runs it through
+// the moderation chain. The returned verdict holds the masked body.
+func (cfg *apiConfig) moderateChirpBody(body string) (moderation.Verdict, error) {
+	if len(body) > maxChirpLength {
+		return moderation.Verdict{}, errChirpTooLong
+	}
+
+	verdict := cfg.moderator.Moderate(body)
+	if verdict.Action == moderation.ActionReject {
+		return verdict, errChirpRejected
+	}
+	return verdict, nil
+}
+
+// flagChirp queues a chirp for review when moderation flagged it.
+func flagChirp(ctx context.Context, q *database.Queries, chirpID uuid.UUID, verdict moderation.Verdict) error {
+	if verdict.Action != moderation.ActionFlag {
+		return nil
+	}
+	return q.CreateChirpFlag(ctx, database.CreateChirpFlagParams{
+		ChirpID: chirpID,
+		Reasons: strings.Join(verdict.Reasons, "; "),
+	})
+}
+
+// reloadModerationWords rebuilds the word list from the optional word list
+// file and the moderation_words table. Entries in the table win.
+func (cfg *apiConfig) reloadModerationWords(ctx context.Context) error {
+	words := map[string]moderation.Action{}
+
+	if cfg.moderationWordsFile != "" {
+		fileWords, err := moderation.LoadWordListFile(cfg.moderationWordsFile)
+		if err != nil {
+			return err
+		}
+		for word, action := range fileWords {
+			words[word] = action
+		}
+	}
+
+	rows, err := cfg.dbQueries.ListModerationWords(ctx)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		words[row.Word] = moderation.Action(row.Action)
+	}
+
+	cfg.moderationWords.Set(words)
+	return nil
+}
+
+type moderationWordResponse struct {
+	Word       string    `json:"word"`
+	Action     string    `json:"action"`
+	Updated_at time.Time `json:"updated_at"`
+}
+
+func (cfg *apiConfig) handlerListModerationWords(w http.ResponseWriter, r *http.Request) {
+	words, err := cfg.dbQueries.ListModerationWords(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting moderation words", err)
+		return
+	}
+
+	response := make([]moderationWordResponse, 0, len(words))
+	for _, word := range words {
+		response = append(response, moderationWordResponse{
+			Word:       word.Word,
+			Action:     word.Action,
+			Updated_at: word.UpdatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerPutModerationWord(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Action string `json:"action"`
+	}
+
+	word := moderation.Normalize(strings.TrimSpace(r.PathValue("word")))
+	if word == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid word", nil)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	action, err := moderation.ParseAction(params.Action)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "action must be one of mask, flag or reject", err)
+		return
+	}
+
+	saved, err := cfg.dbQueries.UpsertModerationWord(r.Context(), database.UpsertModerationWordParams{
+		Word:   word,
+		Action: string(action),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while saving moderation word", err)
+		return
+	}
+
+	err = cfg.reloadModerationWords(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while reloading moderation words", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, moderationWordResponse{
+		Word:       saved.Word,
+		Action:     saved.Action,
+		Updated_at: saved.UpdatedAt,
+	})
+}
+
+func (cfg *apiConfig) handlerDeleteModerationWord(w http.ResponseWriter, r *http.Request) {
+	word := moderation.Normalize(strings.TrimSpace(r.PathValue("word")))
+	deleted, err := cfg.dbQueries.DeleteModerationWord(r.Context(), word)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while deleting moderation word", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Word is not on the list", nil)
+		return
+	}
+
+	err = cfg.reloadModerationWords(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while reloading moderation words", err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// pageCursor marks the last row of a page. It is handed to clients as an
+// opaque string so the keyset columns can change without breaking them.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func encodeCursor(c pageCursor) string {
+	raw := fmt.Sprintf("%d_%s", c.CreatedAt.UnixMicro(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+
+	micros, idString, ok := strings.Cut(string(raw), "_")
+	if !ok {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+
+	unixMicro, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+
+	id, err := uuid.Parse(idString)
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+
+	return pageCursor{
+		CreatedAt: time.UnixMicro(unixMicro).UTC(),
+		ID:        id,
+	}, nil
+}
+
+// pageParams holds the keyset pagination options parsed from a query string.
+type pageParams struct {
+	Limit  int32
+	Cursor *pageCursor
+}
+
+func parsePageParams(query url.Values) (pageParams, error) {
+	limit, err := parseLimit(query)
+	if err != nil {
+		return pageParams{}, err
+	}
+	params := pageParams{Limit: limit}
+
+	if cursorString := query.Get("cursor"); cursorString != "" {
+		cursor, err := decodeCursor(cursorString)
+		if err != nil {
+			return pageParams{}, err
+		}
+		params.Cursor = &cursor
+	}
+
+	return params, nil
+}
+
+func parseLimit(query url.Values) (int32, error) {
+	limitString := query.Get("limit")
+	if limitString == "" {
+		return defaultPageLimit, nil
+	}
+
+	limit, err := strconv.Atoi(limitString)
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+	}
+	return int32(limit), nil
+}
+
+// queryLimit asks the database for one extra row so we know whether another
+// page exists without running a separate count query.
+func (p pageParams) queryLimit() int32 {
+	return p.Limit + 1
+}
+
+func (p pageParams) cursorCreatedAt() sql.NullTime {
+	if p.Cursor == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: p.Cursor.CreatedAt, Valid: true}
+}
+
+func (p pageParams) cursorID() uuid.NullUUID {
+	if p.Cursor == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: p.Cursor.ID, Valid: true}
+}
+
+// trimPage drops the extra row fetched by queryLimit and returns the cursor
+// for the next page, or nil when there is none.
+func trimPage[T any](items []T, limit int32, cursorOf func(T) pageCursor) ([]T, *pageCursor) {
+	if len(items) <= int(limit) {
+		return items, nil
+	}
+	items = items[:limit]
+	next := cursorOf(items[len(items)-1])
+	return items, &next
+}
+
+// setNextPageHeaders advertises the next page through a Link header and an
+// X-Next-Cursor header. Nothing is set when this was the last page.
+func setNextPageHeaders(w http.ResponseWriter, r *http.Request, next *pageCursor) {
+	if next == nil {
+		return
+	}
+	setNextCursorHeaders(w, r, encodeCursor(*next))
+}
+
+// setNextCursorHeaders is setNextPageHeaders for endpoints with their own
+// cursor format.
+func setNextCursorHeaders(w http.ResponseWriter, r *http.Request, cursor string) {
+	query := r.URL.Query()
+	query.Set("cursor", cursor)
+	nextURL := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", nextURL.String()))
+	w.Header().Set("X-Next-Cursor", cursor)
+}
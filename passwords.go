@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/auth"
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/alexanderarrr/chirpy-http-server/internal/mail"
+	"github.com/google/uuid"
+)
+
+const (
+	passwordResetTTL = time.Hour
+	// An account gets at most one reset email a minute and
+	// maxPasswordResetsPerDay a day.
+	passwordResetInterval   = time.Minute
+	maxPasswordResetsPerDay = 5
+	// passwordResetSendTimeout bounds the lookup and delivery, which run
+	// after the response was sent.
+	passwordResetSendTimeout = 30 * time.Second
+)
+
+// handlerForgotPassword mails a reset token to the given address. It
+// answers the same way and just as fast whether or not the address belongs
+// to an account, so the reset is sent in the background.
+func (cfg *apiConfig) handlerForgotPassword(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	type returnVals struct {
+		Message string `json:"message"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	go cfg.requestPasswordReset(params.Email)
+
+	respondWithJSON(w, http.StatusAccepted, returnVals{
+		Message: "If an account uses this email, a reset token is on its way",
+	})
+}
+
+// requestPasswordReset sends a reset token to the account using email, if
+// there is one and it isn't over its limit. Failures are only logged, since
+// reporting them would tell the caller the account exists.
+func (cfg *apiConfig) requestPasswordReset(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), passwordResetSendTimeout)
+	defer cancel()
+
+	user, err := cfg.dbQueries.GetUser(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error while getting user for password reset: %v", err)
+		return
+	}
+
+	limits := []struct {
+		window time.Duration
+		max    int64
+	}{
+		{passwordResetInterval, 1},
+		{24 * time.Hour, maxPasswordResetsPerDay},
+	}
+	for _, limit := range limits {
+		count, err := cfg.dbQueries.CountPasswordResetsSince(ctx, database.CountPasswordResetsSinceParams{
+			UserID:    user.ID,
+			CreatedAt: time.Now().Add(-limit.window),
+		})
+		if err != nil {
+			log.Printf("Error while checking password reset limit: %v", err)
+			return
+		}
+		if count >= limit.max {
+			return
+		}
+	}
+
+	err = cfg.sendPasswordReset(ctx, user.ID, user.Email)
+	if err != nil {
+		log.Printf("Error while sending password reset: %v", err)
+	}
+}
+
+func (cfg *apiConfig) sendPasswordReset(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+	err = cfg.dbQueries.CreatePasswordReset(ctx, database.CreatePasswordResetParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your Chirpy account.\n\n"+
+			"Your reset token is %s\n\n"+
+			"It expires in %d minutes. If you didn't ask for this, you can ignore this email.",
+			token, int(passwordResetTTL.Minutes())),
+	})
+}
+
+// handlerResetPassword sets a new password using a token from
+// handlerForgotPassword. The token can be used once; a successful reset
+// also voids the user's other reset tokens and signs out every session.
+func (cfg *apiConfig) handlerResetPassword(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	if params.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "password can not be empty", nil)
+		return
+	}
+	hashedPassword, err := auth.HashPassword(params.Password)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Can't use password", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while resetting password", err)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+	reset, err := qtx.UsePasswordReset(r.Context(), auth.HashToken(params.Token))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token", err)
+		return
+	}
+
+	err = qtx.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		HashedPassword: hashedPassword,
+		ID:             reset.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while resetting password", err)
+		return
+	}
+
+	err = qtx.ExpireUserPasswordResets(r.Context(), reset.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while resetting password", err)
+		return
+	}
+
+	err = qtx.RevokeUserRefreshTokens(r.Context(), reset.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while resetting password", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while resetting password", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
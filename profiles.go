@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/auth"
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxLocationLength    = 30
+	maxWebsiteLength     = 100
+	maxAvatarURLLength   = 500
+)
+
+type profileResponse struct {
+	Id              uuid.UUID `json:"id"`
+	Created_at      time.Time `json:"created_at"`
+	Handle          string    `json:"handle,omitempty"`
+	DisplayName     string    `json:"display_name"`
+	Bio             string    `json:"bio"`
+	Location        string    `json:"location"`
+	Website         string    `json:"website"`
+	AvatarURL       string    `json:"avatar_url"`
+	IsChirpyRed     bool      `json:"is_chirpy_red"`
+	Follower_count  int64     `json:"follower_count"`
+	Following_count int64     `json:"following_count"`
+}
+
+func newProfileResponse(profile database.GetUserProfileRow) profileResponse {
+	return profileResponse{
+		Id:              profile.ID,
+		Created_at:      profile.CreatedAt,
+		Handle:          profile.Handle.String,
+		DisplayName:     profile.DisplayName,
+		Bio:             profile.Bio,
+		Location:        profile.Location,
+		Website:         profile.Website,
+		AvatarURL:       profile.AvatarUrl,
+		IsChirpyRed:     profile.IsChirpyRed.Bool,
+		Follower_count:  profile.FollowerCount,
+		Following_count: profile.FollowingCount,
+	}
+}
+
+// authorResponse is the compact profile embedded in chirp responses.
+type authorResponse struct {
+	Id          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle,omitempty"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url"`
+}
+
+// attachAuthors fills in the author of every chirp in place.
+func (cfg *apiConfig) attachAuthors(ctx context.Context, chirps []chirpResponse) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		userIDs = append(userIDs, chirp.User_id)
+	}
+
+	authors, err := cfg.dbQueries.GetChirpAuthors(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[uuid.UUID]authorResponse, len(authors))
+	for _, author := range authors {
+		byID[author.ID] = authorResponse{
+			Id:          author.ID,
+			Handle:      author.Handle.String,
+			DisplayName: author.DisplayName,
+			AvatarURL:   author.AvatarUrl,
+		}
+	}
+
+	for i := range chirps {
+		if author, ok := byID[chirps[i].User_id]; ok {
+			chirps[i].Author = &author
+		}
+	}
+	return nil
+}
+
+// parseProfileText trims a free text profile field and checks its length.
+// A nil value leaves the field unchanged, an empty one clears it.
+func parseProfileText(field string, value *string, maxLength int) (sql.NullString, error) {
+	if value == nil {
+		return sql.NullString{}, nil
+	}
+
+	text := strings.TrimSpace(*value)
+	if utf8.RuneCountInString(text) > maxLength {
+		return sql.NullString{}, fmt.Errorf("%s can be at most %d characters long", field, maxLength)
+	}
+	return sql.NullString{String: text, Valid: true}, nil
+}
+
+// parseProfileURL is parseProfileText for fields holding an http(s) URL.
+func parseProfileURL(field string, value *string, maxLength int) (sql.NullString, error) {
+	text, err := parseProfileText(field, value, maxLength)
+	if err != nil || text.String == "" {
+		return text, err
+	}
+
+	parsed, err := url.Parse(text.String)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return sql.NullString{}, fmt.Errorf("%s must be an http or https URL", field)
+	}
+	return text, nil
+}
+
+func (cfg *apiConfig) handlerGetUserProfile(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	profile, err := cfg.dbQueries.GetUserProfile(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Can't find user", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newProfileResponse(profile))
+}
+
+// handlerPatchMe updates the fields of the signed in user's account that
+// are present in the request body and leaves the others alone. Changing
+// the email or password requires the current password; a new email only
+// takes effect once it is confirmed.
+func (cfg *apiConfig) handlerPatchMe(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+		Location    *string `json:"location"`
+		Website     *string `json:"website"`
+		AvatarURL   *string `json:"avatar_url"`
+		// AvatarMediaID sets the avatar to the thumbnail of an upload.
+		AvatarMediaID   *uuid.UUID `json:"avatar_media_id"`
+		Email           *string    `json:"email"`
+		Password        *string    `json:"password"`
+		CurrentPassword string     `json:"current_password"`
+	}
+
+	type returnVals struct {
+		profileResponse
+		Email        string `json:"email"`
+		PendingEmail string `json:"pending_email,omitempty"`
+		// A password change signs out every session, so the caller gets
+		// a fresh pair of tokens.
+		Token         string `json:"token,omitempty"`
+		Refresh_token string `json:"refresh_token,omitempty"`
+	}
+
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	update := database.UpdateUserProfileParams{ID: userID}
+	if params.Handle != nil {
+		update.Handle, err = parseHandleParam(*params.Handle)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+	}
+
+	fields := []struct {
+		dst       *sql.NullString
+		name      string
+		value     *string
+		maxLength int
+		isURL     bool
+	}{
+		{&update.DisplayName, "display_name", params.DisplayName, maxDisplayNameLength, false},
+		{&update.Bio, "bio", params.Bio, maxBioLength, false},
+		{&update.Location, "location", params.Location, maxLocationLength, false},
+		{&update.Website, "website", params.Website, maxWebsiteLength, true},
+		{&update.AvatarUrl, "avatar_url", params.AvatarURL, maxAvatarURLLength, true},
+	}
+	for _, field := range fields {
+		if field.isURL {
+			*field.dst, err = parseProfileURL(field.name, field.value, field.maxLength)
+		} else {
+			*field.dst, err = parseProfileText(field.name, field.value, field.maxLength)
+		}
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+	}
+
+	if params.AvatarMediaID != nil {
+		if params.AvatarURL != nil {
+			respondWithError(w, http.StatusBadRequest, "Set either avatar_url or avatar_media_id, not both", nil)
+			return
+		}
+		files, err := cfg.getOwnMedia(r.Context(), []uuid.UUID{*params.AvatarMediaID}, userID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "You can only use media you uploaded as your avatar", err)
+			return
+		}
+		update.AvatarUrl = sql.NullString{String: cfg.blobs.URL(files[0].ThumbnailKey), Valid: true}
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting user", err)
+		return
+	}
+
+	change := credentialChange{}
+	if params.Email != nil || params.Password != nil {
+		// profile:write covers the public profile, not signing in.
+		if usesPersonalAccessToken(r) {
+			respondWithError(w, http.StatusForbidden, "Personal access tokens can't change the email or password", nil)
+			return
+		}
+		err = auth.CheckPasswordHash(user.HashedPassword, params.CurrentPassword)
+		if err != nil {
+			respondWithError(w, http.StatusForbidden, "current_password is incorrect", err)
+			return
+		}
+	}
+	if params.Email != nil {
+		email, err := parseEmailParam(*params.Email)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		if email != user.Email {
+			_, err = cfg.dbQueries.GetUser(r.Context(), email)
+			if err == nil {
+				respondWithError(w, http.StatusConflict, "Email already taken", nil)
+				return
+			}
+			change.email = email
+		}
+	}
+	if params.Password != nil {
+		if *params.Password == "" {
+			respondWithError(w, http.StatusBadRequest, "password can not be empty", nil)
+			return
+		}
+		change.hashedPassword, err = auth.HashPassword(*params.Password)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Can't use password", err)
+			return
+		}
+	}
+
+	result, err := cfg.updateAccount(r.Context(), update, change, newSessionClient(r, ""))
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Handle already taken", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while updating account", err)
+		return
+	}
+
+	if result.emailToken != "" {
+		err = cfg.sendEmailChangeConfirmation(r.Context(), change.email, result.emailToken)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error while sending confirmation email", err)
+			return
+		}
+	}
+
+	profile, err := cfg.dbQueries.GetUserProfile(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting profile", err)
+		return
+	}
+
+	response := returnVals{
+		profileResponse: newProfileResponse(profile),
+		Email:           user.Email,
+		Refresh_token:   result.refreshToken,
+	}
+
+	pending, err := cfg.dbQueries.GetEmailChange(r.Context(), userID)
+	if err == nil {
+		response.PendingEmail = pending.NewEmail
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting pending email", err)
+		return
+	}
+
+	if result.refreshToken != "" {
+		response.Token, err = cfg.keys.MakeSessionJWT(userID, result.sessionID, time.Hour, user.Roles...)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error while creating access token", err)
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
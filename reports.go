@@ -0,0 +1,465 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxReportDetailsLength = 500
+	defaultSuspensionDays  = 7
+	maxSuspensionDays      = 365
+)
+
+var reportReasons = map[string]bool{
+	"spam":           true,
+	"harassment":     true,
+	"hate":           true,
+	"violence":       true,
+	"misinformation": true,
+	"other":          true,
+}
+
+// Decisions a moderator can take on a reported or flagged chirp.
+const (
+	moderationHideChirp     = "hide_chirp"
+	moderationDismiss       = "dismiss"
+	moderationSuspendAuthor = "suspend_author"
+)
+
+type reportResponse struct {
+	Id          uuid.UUID  `json:"id"`
+	Chirp_id    *uuid.UUID `json:"chirp_id"`
+	Reporter_id uuid.UUID  `json:"reporter_id"`
+	Reason      string     `json:"reason"`
+	Details     string     `json:"details"`
+	Status      string     `json:"status"`
+	Created_at  time.Time  `json:"created_at"`
+	Resolved_at *time.Time `json:"resolved_at,omitempty"`
+}
+
+func newReportResponse(report database.Report) reportResponse {
+	return reportResponse{
+		Id:          report.ID,
+		Chirp_id:    nullUUIDPtr(report.ChirpID),
+		Reporter_id: report.ReporterID,
+		Reason:      report.Reason,
+		Details:     report.Details,
+		Status:      report.Status,
+		Created_at:  report.CreatedAt,
+		Resolved_at: nullTimePtr(report.ResolvedAt),
+	}
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func (cfg *apiConfig) handlerCreateReport(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	if !reportReasons[params.Reason] {
+		respondWithError(w, http.StatusBadRequest, "reason must be one of spam, harassment, hate, violence, misinformation or other", nil)
+		return
+	}
+	if len(params.Details) > maxReportDetailsLength {
+		respondWithError(w, http.StatusBadRequest, "Report details are too long", nil)
+		return
+	}
+
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Could not get chirp", err)
+		return
+	}
+	if chirp.UserID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can not report your own chirp", nil)
+		return
+	}
+
+	report, err := cfg.dbQueries.CreateReport(r.Context(), database.CreateReportParams{
+		ChirpID:    uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ReporterID: userID,
+		Reason:     params.Reason,
+		Details:    params.Details,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "You already reported this chirp", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while creating report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newReportResponse(report))
+}
+
+func (cfg *apiConfig) handlerListOpenReports(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	reports, err := cfg.dbQueries.ListOpenReports(r.Context(), database.ListOpenReportsParams{
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageLimit:       page.queryLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting reports", err)
+		return
+	}
+
+	reports, nextCursor := trimPage(reports, page.Limit, func(report database.Report) pageCursor {
+		return pageCursor{CreatedAt: report.CreatedAt, ID: report.ID}
+	})
+
+	response := make([]reportResponse, 0, len(reports))
+	for _, report := range reports {
+		response = append(response, newReportResponse(report))
+	}
+
+	setNextPageHeaders(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerListOpenFlags lists chirps that automatic moderation queued for
+// review, next to the user reports.
+func (cfg *apiConfig) handlerListOpenFlags(w http.ResponseWriter, r *http.Request) {
+	type returnVals struct {
+		Id         uuid.UUID `json:"id"`
+		Chirp_id   uuid.UUID `json:"chirp_id"`
+		Reasons    string    `json:"reasons"`
+		Created_at time.Time `json:"created_at"`
+	}
+
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	flags, err := cfg.dbQueries.ListOpenChirpFlags(r.Context(), database.ListOpenChirpFlagsParams{
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageLimit:       page.queryLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting flags", err)
+		return
+	}
+
+	flags, nextCursor := trimPage(flags, page.Limit, func(flag database.ChirpFlag) pageCursor {
+		return pageCursor{CreatedAt: flag.CreatedAt, ID: flag.ID}
+	})
+
+	response := make([]returnVals, 0, len(flags))
+	for _, flag := range flags {
+		response = append(response, returnVals{
+			Id:         flag.ID,
+			Chirp_id:   flag.ChirpID,
+			Reasons:    flag.Reasons,
+			Created_at: flag.CreatedAt,
+		})
+	}
+
+	setNextPageHeaders(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+type moderationActionResponse struct {
+	Id           uuid.UUID  `json:"id"`
+	Report_id    *uuid.UUID `json:"report_id,omitempty"`
+	Moderator_id *uuid.UUID `json:"moderator_id,omitempty"`
+	Action       string     `json:"action"`
+	Chirp_body   string     `json:"chirp_body"`
+	Note         string     `json:"note"`
+	Created_at   time.Time  `json:"created_at"`
+}
+
+// handlerGetModerationContext shows a moderator everything needed to decide
+// on a chirp: the chirp itself, hidden or not, the chirp it replies to, its
+// author, every report filed against it and the decisions taken so far.
+func (cfg *apiConfig) handlerGetModerationContext(w http.ResponseWriter, r *http.Request) {
+	type authorVals struct {
+		Id              uuid.UUID  `json:"id"`
+		Email           string     `json:"email"`
+		Handle          string     `json:"handle,omitempty"`
+		Suspended_until *time.Time `json:"suspended_until,omitempty"`
+	}
+	type returnVals struct {
+		Chirp   chirpResponse              `json:"chirp"`
+		Parent  *chirpResponse             `json:"parent,omitempty"`
+		Author  authorVals                 `json:"author"`
+		Reports []reportResponse           `json:"reports"`
+		Actions []moderationActionResponse `json:"actions"`
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	chirp, err := cfg.getChirpForModeration(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Could not get chirp", err)
+		return
+	}
+
+	ids := []uuid.UUID{chirp.ID}
+	if chirp.InReplyTo.Valid {
+		ids = append(ids, chirp.InReplyTo.UUID)
+	}
+	chirps, err := cfg.dbQueries.GetChirpsByIDs(r.Context(), ids)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting chirps", err)
+		return
+	}
+
+	// Moderators see hidden chirps as they are, so the responses skip
+	// decorateChirps and its redaction.
+	responses := newChirpResponses(chirps)
+	err = cfg.attachMentions(r.Context(), responses)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting chirp details", err)
+		return
+	}
+	err = cfg.attachMedia(r.Context(), responses)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting chirp details", err)
+		return
+	}
+
+	response := returnVals{
+		Reports: []reportResponse{},
+		Actions: []moderationActionResponse{},
+	}
+	for i := range responses {
+		if responses[i].Id == chirp.ID {
+			response.Chirp = responses[i]
+		} else {
+			response.Parent = &responses[i]
+		}
+	}
+
+	author, err := cfg.dbQueries.GetUserByID(r.Context(), chirp.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting author", err)
+		return
+	}
+	response.Author = authorVals{
+		Id:              author.ID,
+		Email:           author.Email,
+		Handle:          author.Handle.String,
+		Suspended_until: nullTimePtr(author.SuspendedUntil),
+	}
+
+	reports, err := cfg.dbQueries.ListChirpReports(r.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting reports", err)
+		return
+	}
+	for _, report := range reports {
+		response.Reports = append(response.Reports, newReportResponse(report))
+	}
+
+	actions, err := cfg.dbQueries.ListChirpModerationActions(r.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting moderation history", err)
+		return
+	}
+	for _, action := range actions {
+		response.Actions = append(response.Actions, moderationActionResponse{
+			Id:           action.ID,
+			Report_id:    nullUUIDPtr(action.ReportID),
+			Moderator_id: nullUUIDPtr(action.ModeratorID),
+			Action:       action.Action,
+			Chirp_body:   action.ChirpBody,
+			Note:         action.Note,
+			Created_at:   action.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// getChirpForModeration loads a chirp regardless of whether it is hidden or
+// deleted.
+func (cfg *apiConfig) getChirpForModeration(ctx context.Context, chirpID uuid.UUID) (database.Chirp, error) {
+	chirps, err := cfg.dbQueries.GetChirpsByIDs(ctx, []uuid.UUID{chirpID})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if len(chirps) == 0 {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirps[0], nil
+}
+
+// handlerModerateChirp records a moderator's decision on a chirp. Every
+// decision closes the open reports and flags on the chirp.
+func (cfg *apiConfig) handlerModerateChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Action         string     `json:"action"`
+		Note           string     `json:"note"`
+		ReportID       *uuid.UUID `json:"report_id"`
+		SuspensionDays int        `json:"suspension_days"`
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	switch params.Action {
+	case moderationHideChirp, moderationDismiss:
+	case moderationSuspendAuthor:
+		if params.SuspensionDays == 0 {
+			params.SuspensionDays = defaultSuspensionDays
+		}
+		if params.SuspensionDays < 1 || params.SuspensionDays > maxSuspensionDays {
+			respondWithError(w, http.StatusBadRequest, "suspension_days must be between 1 and 365", nil)
+			return
+		}
+	default:
+		respondWithError(w, http.StatusBadRequest, "action must be one of hide_chirp, dismiss or suspend_author", nil)
+		return
+	}
+
+	chirp, err := cfg.getChirpForModeration(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Could not get chirp", err)
+		return
+	}
+
+	if params.Action == moderationSuspendAuthor {
+		author, err := cfg.dbQueries.GetUserByID(r.Context(), chirp.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error while getting author", err)
+			return
+		}
+		if hasRole(author.Roles, roleAdmin) && !hasRole(requestClaims(r).Roles, roleAdmin) {
+			respondWithError(w, http.StatusForbidden, "Only admins can suspend admins", nil)
+			return
+		}
+	}
+
+	reportID := uuid.NullUUID{}
+	if params.ReportID != nil {
+		report, err := cfg.dbQueries.GetReport(r.Context(), *params.ReportID)
+		if err != nil || report.ChirpID.UUID != chirp.ID {
+			respondWithError(w, http.StatusBadRequest, "Report does not belong to this chirp", err)
+			return
+		}
+		reportID = uuid.NullUUID{UUID: report.ID, Valid: true}
+	}
+
+	err = cfg.moderateChirp(r.Context(), chirp, params.Action, params.SuspensionDays, database.CreateModerationActionParams{
+		ChirpID:     uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ChirpBody:   chirp.Body,
+		ReportID:    reportID,
+		ModeratorID: requestUserID(r),
+		Action:      params.Action,
+		Note:        params.Note,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while moderating chirp", err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// moderateChirp applies a decision, closes the chirp's open reports and
+// flags, and records the decision, all in one transaction.
+func (cfg *apiConfig) moderateChirp(ctx context.Context, chirp database.Chirp, action string, suspensionDays int, record database.CreateModerationActionParams) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+	status := "resolved"
+	switch action {
+	case moderationHideChirp:
+		err = qtx.HideChirp(ctx, chirp.ID)
+	case moderationDismiss:
+		status = "dismissed"
+	case moderationSuspendAuthor:
+		err = suspendUser(ctx, qtx, chirp.UserID, time.Now().AddDate(0, 0, suspensionDays), record.Note)
+	default:
+		err = errors.New("unknown moderation action")
+	}
+	if err != nil {
+		return err
+	}
+
+	err = qtx.CloseChirpReports(ctx, database.CloseChirpReportsParams{
+		Status:  status,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = qtx.ResolveChirpFlags(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+
+	err = qtx.CreateModerationAction(ctx, record)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/alexanderarrr/chirpy-http-server/internal/moderation"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerUpdateChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	verdict, err := cfg.moderateChirpBody(params.Body)
+	if errors.Is(err, errChirpRejected) {
+		respondWithError(w, http.StatusBadRequest, "Chirp was rejected by moderation", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long", err)
+		return
+	}
+
+	chirp, err := cfg.getOwnChirp(r.Context(), chirpID, userID)
+	if errors.Is(err, errNotChirpOwner) {
+		respondWithError(w, http.StatusForbidden, "You can only edit your own chirps", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Specified chirp does not exist", err)
+		return
+	}
+
+	if chirp.RechirpOf.Valid {
+		respondWithError(w, http.StatusBadRequest, "Rechirps can not be edited", nil)
+		return
+	}
+
+	if verdict.Body != chirp.Body {
+		chirp, err = cfg.updateChirpBody(r.Context(), chirp, verdict)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error while updating chirp", err)
+			return
+		}
+	}
+
+	response := []chirpResponse{newChirpResponse(chirp)}
+	err = cfg.decorateChirps(r.Context(), response, userViewer(userID))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting chirp details", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response[0])
+}
+
+// updateChirpBody stores the current body as a revision and replaces it in a
+// single transaction. The chirp's hashtags and mentions are refreshed along
+// with it, and the new body is queued for review if moderation flagged it.
+func (cfg *apiConfig) updateChirpBody(ctx context.Context, chirp database.Chirp, verdict moderation.Verdict) (database.Chirp, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+	err = qtx.CreateChirpRevision(ctx, database.CreateChirpRevisionParams{
+		ChirpID: chirp.ID,
+		Body:    chirp.Body,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	updated, err := qtx.UpdateChirpBody(ctx, database.UpdateChirpBodyParams{
+		Body:   verdict.Body,
+		ID:     chirp.ID,
+		UserID: chirp.UserID,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	err = indexChirpBody(ctx, qtx, updated)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	err = flagChirp(ctx, qtx, updated.ID, verdict)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	return updated, tx.Commit()
+}
+
+func (cfg *apiConfig) handlerGetChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	v := cfg.viewer(r)
+	_, err = cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+		ID:                chirpID,
+		ViewerID:          v.ID,
+		ViewerIsModerator: v.Moderator,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Could not get chirp", err)
+		return
+	}
+
+	revisions, err := cfg.dbQueries.ListChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting revisions", err)
+		return
+	}
+
+	type returnVals struct {
+		Id         uuid.UUID `json:"id"`
+		Body       string    `json:"body"`
+		Created_at time.Time `json:"created_at"`
+	}
+
+	response := make([]returnVals, 0, len(revisions))
+	for _, revision := range revisions {
+		response = append(response, returnVals{
+			Id:         revision.ID,
+			Body:       revision.Body,
+			Created_at: revision.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"slices"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/auth"
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	roleAdmin     = "admin"
+	roleModerator = "moderator"
+)
+
+var validRoles = map[string]bool{
+	roleAdmin:     true,
+	roleModerator: true,
+}
+
+// hasRole reports whether roles satisfy role. Admins can do everything
+// moderators can.
+func hasRole(roles []string, role string) bool {
+	return slices.Contains(roles, role) || slices.Contains(roles, roleAdmin)
+}
+
+type claimsContextKey struct{}
+
+// requireRole only lets requests through whose access token carries role.
+// Requests without a valid token get 401, signed in users without the role
+// and suspended users get 403. The token's claims are available to next via requestClaims.
+func (cfg *apiConfig) requireRole(role string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := cfg.authenticateClaims(r)
+		if err != nil {
+			respondWithAuthError(w, err)
+			return
+		}
+
+		if !hasRole(claims.Roles, role) {
+			respondWithError(w, http.StatusForbidden, "You are not allowed to do this", nil)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), claimsContextKey{}, claims)
+		next(w, r.WithContext(ctx))
+	})
+}
+
+// requestClaims returns the claims stored by requireRole, if any.
+func requestClaims(r *http.Request) *auth.Claims {
+	claims, _ := r.Context().Value(claimsContextKey{}).(*auth.Claims)
+	return claims
+}
+
+// requestUserID is the ID of the user that passed requireRole.
+func requestUserID(r *http.Request) uuid.NullUUID {
+	claims := requestClaims(r)
+	if claims == nil {
+		return uuid.NullUUID{}
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
+
+func (cfg *apiConfig) handlerGrantRole(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := parseRolePath(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := cfg.dbQueries.GrantUserRole(r.Context(), database.GrantUserRoleParams{
+		Role: role,
+		ID:   userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while granting role", err)
+		return
+	}
+	if updated == 0 {
+		respondWithError(w, http.StatusNotFound, "User does not exist", nil)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerRevokeRole(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := parseRolePath(w, r)
+	if !ok {
+		return
+	}
+
+	// Keep at least one way into the admin endpoints.
+	if role == roleAdmin && requestUserID(r) == (uuid.NullUUID{UUID: userID, Valid: true}) {
+		respondWithError(w, http.StatusBadRequest, "You can not revoke your own admin role", nil)
+		return
+	}
+
+	updated, err := revokeUserRole(r.Context(), cfg.db, &cfg.dbQueries, userID, role)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while revoking role", err)
+		return
+	}
+	if updated == 0 {
+		respondWithError(w, http.StatusNotFound, "User does not exist", nil)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// revokeUserRole takes role away from the user and ends their sessions in
+// one transaction. Access tokens carry the roles they were issued with, and
+// ending the sessions voids them, so the role is gone right away instead of
+// when they expire. It returns 0 if the user doesn't exist.
+func revokeUserRole(ctx context.Context, db *sql.DB, q *database.Queries, userID uuid.UUID, role string) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	qtx := q.WithTx(tx)
+	updated, err := qtx.RevokeUserRole(ctx, database.RevokeUserRoleParams{
+		Role: role,
+		ID:   userID,
+	})
+	if err != nil || updated == 0 {
+		return updated, err
+	}
+
+	err = qtx.RevokeUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return updated, tx.Commit()
+}
+
+func parseRolePath(w http.ResponseWriter, r *http.Request) (uuid.UUID, string, bool) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return uuid.Nil, "", false
+	}
+
+	role := r.PathValue("role")
+	if !validRoles[role] {
+		respondWithError(w, http.StatusBadRequest, "role must be either admin or moderator", nil)
+		return uuid.Nil, "", false
+	}
+	return userID, role, true
+}
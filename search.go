@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxSearchQueryLength = 256
+
+// searchCursor extends pageCursor with the score of the last result, since
+// search results are ordered by score first and recency second. Scores
+// decay with age relative to RankedAt, which is when the first page was
+// fetched, so they don't shift while paging.
+type searchCursor struct {
+	Score    float32
+	RankedAt time.Time
+	pageCursor
+}
+
+func encodeSearchCursor(c searchCursor) string {
+	raw := fmt.Sprintf("%d_%d_%s", math.Float32bits(c.Score), c.RankedAt.UnixMicro(), encodeCursor(c.pageCursor))
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSearchCursor(s string) (searchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return searchCursor{}, errors.New("malformed cursor")
+	}
+
+	parts := strings.SplitN(string(raw), "_", 3)
+	if len(parts) != 3 {
+		return searchCursor{}, errors.New("malformed cursor")
+	}
+
+	scoreBits, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return searchCursor{}, errors.New("malformed cursor")
+	}
+
+	rankedAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return searchCursor{}, errors.New("malformed cursor")
+	}
+
+	cursor, err := decodeCursor(parts[2])
+	if err != nil {
+		return searchCursor{}, err
+	}
+
+	return searchCursor{
+		Score:      math.Float32frombits(uint32(scoreBits)),
+		RankedAt:   time.UnixMicro(rankedAt).UTC(),
+		pageCursor: cursor,
+	}, nil
+}
+
+// handlerSearchChirps runs a PostgreSQL full-text search over chirp bodies.
+// The q parameter uses web search syntax, so "quoted phrases", OR and
+// -excluded words all work. Results are ordered by score, the text rank
+// divided by one plus the chirp's age in days, so newer matches win over
+// slightly better old ones.
+func (cfg *apiConfig) handlerSearchChirps(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	searchQuery := strings.TrimSpace(query.Get("q"))
+	if searchQuery == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing search query", nil)
+		return
+	}
+	if len(searchQuery) > maxSearchQueryLength {
+		respondWithError(w, http.StatusBadRequest, "Search query is too long", nil)
+		return
+	}
+
+	limit, err := parseLimit(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	v := cfg.viewer(r)
+	params := database.SearchChirpsParams{
+		Query:             searchQuery,
+		ViewerID:          v.ID,
+		ViewerIsModerator: v.Moderator,
+		RankedAt:          time.Now().UTC(),
+		PageLimit:         limit + 1,
+	}
+
+	if authorIDString := query.Get("author_id"); authorIDString != "" {
+		authorID, err := uuid.Parse(authorIDString)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author ID", err)
+			return
+		}
+		params.AuthorID = uuid.NullUUID{UUID: authorID, Valid: true}
+	}
+
+	if cursorString := query.Get("cursor"); cursorString != "" {
+		cursor, err := decodeSearchCursor(cursorString)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		params.RankedAt = cursor.RankedAt
+		params.CursorScore = sql.NullFloat64{Float64: float64(cursor.Score), Valid: true}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	rows, err := cfg.dbQueries.SearchChirps(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while searching chirps", err)
+		return
+	}
+
+	if len(rows) > int(limit) {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		setNextCursorHeaders(w, r, encodeSearchCursor(searchCursor{
+			Score:      last.Score,
+			RankedAt:   params.RankedAt,
+			pageCursor: chirpCursor(last.Chirp),
+		}))
+	}
+
+	response := make([]chirpResponse, 0, len(rows))
+	for _, row := range rows {
+		response = append(response, newChirpResponse(row.Chirp))
+	}
+
+	err = cfg.decorateChirps(r.Context(), response, v)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting chirp details", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
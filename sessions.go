@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+// errSessionRevoked rejects access tokens of a session that was signed out.
+var errSessionRevoked = errors.New("session was signed out")
+
+type sessionResponse struct {
+	Id           uuid.UUID `json:"id"`
+	DeviceName   string    `json:"device_name"`
+	UserAgent    string    `json:"user_agent"`
+	IpAddress    string    `json:"ip_address"`
+	Signed_in_at time.Time `json:"signed_in_at"`
+	Last_used_at time.Time `json:"last_used_at"`
+	Expires_at   time.Time `json:"expires_at"`
+	// Current marks the session the request was made with.
+	Current bool `json:"current"`
+}
+
+// handlerGetSessions lists the signed in user's active sessions, most
+// recently used first.
+func (cfg *apiConfig) handlerGetSessions(w http.ResponseWriter, r *http.Request) {
+	claims, err := cfg.authenticateClaims(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	sessions, err := cfg.dbQueries.ListUserSessions(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting sessions", err)
+		return
+	}
+
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, sessionResponse{
+			Id:           session.FamilyID,
+			DeviceName:   session.DeviceName,
+			UserAgent:    session.UserAgent,
+			IpAddress:    session.IpAddress,
+			Signed_in_at: session.SignedInAt,
+			Last_used_at: session.LastUsedAt,
+			Expires_at:   session.ExpiresAt,
+			Current:      session.FamilyID == claims.Session(),
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerDeleteSession signs out one of the user's sessions. Its refresh
+// token and access tokens stop working right away.
+func (cfg *apiConfig) handlerDeleteSession(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid session ID", err)
+		return
+	}
+
+	revoked, err := cfg.dbQueries.RevokeUserSession(r.Context(), database.RevokeUserSessionParams{
+		UserID:   userID,
+		FamilyID: sessionID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while revoking session", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "Can't find session", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerRevokeOtherSessions signs out every session of the user except the
+// one the request was made with. A token without a session keeps none.
+func (cfg *apiConfig) handlerRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	claims, err := cfg.authenticateClaims(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	err = cfg.dbQueries.RevokeOtherUserSessions(r.Context(), database.RevokeOtherUserSessionsParams{
+		UserID:   userID,
+		FamilyID: claims.Session(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while revoking sessions", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,56 @@
+-- name: BlockUser :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: UnblockUser :exec
+DELETE FROM blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: IsBlocked :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocker_id = $1 AND blocked_id = $2
+)::bool AS blocked;
+
+-- name: DeleteFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = sqlc.arg('user_a') AND followee_id = sqlc.arg('user_b'))
+OR (follower_id = sqlc.arg('user_b') AND followee_id = sqlc.arg('user_a'));
+
+-- name: ListBlockedUsers :many
+SELECT blocked_id AS user_id, created_at FROM blocks
+WHERE blocker_id = sqlc.arg('user_id')
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, blocked_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, blocked_id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: MuteUser :exec
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (muter_id, muted_id) DO NOTHING;
+
+-- name: UnmuteUser :exec
+DELETE FROM mutes
+WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: ListMutedUsers :many
+SELECT muted_id AS user_id, created_at FROM mutes
+WHERE muter_id = sqlc.arg('user_id')
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, muted_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, muted_id DESC
+LIMIT sqlc.arg('page_limit');
\ No newline at end of file
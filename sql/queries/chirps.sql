@@ -1,22 +1,189 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, thread_id, rechirp_of, quote_of)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
 )
 RETURNING *;
 
--- name: GetChirps :many
-SELECT * FROM chirps
-ORDER BY created_at ASC;
-
 -- name: GetChirp :one
 SELECT * FROM chirps
-WHERE id = $1;
+WHERE id = sqlc.arg('id') AND deleted_at IS NULL
+AND (hidden_at IS NULL OR user_id = sqlc.narg('viewer_id')::uuid OR sqlc.arg('viewer_is_moderator')::boolean);
 
 -- name: DeleteChirp :exec
 DELETE FROM chirps
-WHERE id = $1 AND user_id = $2;
\ No newline at end of file
+WHERE id = $1 AND user_id = $2;
+
+-- name: ListChirpsAsc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (
+    rechirp_of IS NULL
+    OR rechirp_of IN (
+        SELECT originals.id FROM chirps AS originals
+        WHERE originals.deleted_at IS NULL
+        AND originals.user_id NOT IN (
+            SELECT muted_id FROM mutes WHERE muter_id = sqlc.narg('viewer_id')::uuid
+            UNION
+            SELECT blocked_id FROM blocks WHERE blocker_id = sqlc.narg('viewer_id')::uuid
+        )
+    )
+)
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND (hidden_at IS NULL OR user_id = sqlc.narg('viewer_id')::uuid OR sqlc.arg('viewer_is_moderator')::boolean)
+AND user_id NOT IN (
+    SELECT muted_id FROM mutes WHERE muter_id = sqlc.narg('viewer_id')::uuid
+    UNION
+    SELECT blocked_id FROM blocks WHERE blocker_id = sqlc.narg('viewer_id')::uuid
+)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListChirpsDesc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (
+    rechirp_of IS NULL
+    OR rechirp_of IN (
+        SELECT originals.id FROM chirps AS originals
+        WHERE originals.deleted_at IS NULL
+        AND originals.user_id NOT IN (
+            SELECT muted_id FROM mutes WHERE muter_id = sqlc.narg('viewer_id')::uuid
+            UNION
+            SELECT blocked_id FROM blocks WHERE blocker_id = sqlc.narg('viewer_id')::uuid
+        )
+    )
+)
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND (hidden_at IS NULL OR user_id = sqlc.narg('viewer_id')::uuid OR sqlc.arg('viewer_is_moderator')::boolean)
+AND user_id NOT IN (
+    SELECT muted_id FROM mutes WHERE muter_id = sqlc.narg('viewer_id')::uuid
+    UNION
+    SELECT blocked_id FROM blocks WHERE blocker_id = sqlc.narg('viewer_id')::uuid
+)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListTimelineChirps :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (
+    rechirp_of IS NULL
+    OR rechirp_of IN (
+        SELECT originals.id FROM chirps AS originals
+        WHERE originals.deleted_at IS NULL
+        AND originals.user_id NOT IN (
+            SELECT muted_id FROM mutes WHERE muter_id = sqlc.arg('user_id')
+            UNION
+            SELECT blocked_id FROM blocks WHERE blocker_id = sqlc.arg('user_id')
+        )
+    )
+)
+AND (
+    user_id = sqlc.arg('user_id')
+    OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg('user_id'))
+)
+AND (hidden_at IS NULL OR user_id = sqlc.arg('user_id') OR sqlc.arg('viewer_is_moderator')::boolean)
+AND user_id NOT IN (
+    SELECT muted_id FROM mutes WHERE muter_id = sqlc.arg('user_id')
+    UNION
+    SELECT blocked_id FROM blocks WHERE blocker_id = sqlc.arg('user_id')
+)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: CountChirpDependents :one
+SELECT COUNT(*) FROM chirps
+WHERE in_reply_to = sqlc.arg('id')::uuid OR quote_of = sqlc.arg('id')::uuid;
+
+-- name: TombstoneChirp :exec
+UPDATE chirps
+SET body = '',
+deleted_at = NOW(),
+updated_at = NOW()
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetChirpThread :many
+WITH root AS (
+    SELECT COALESCE(thread_id, id) AS id FROM chirps
+    WHERE chirps.id = $1
+)
+SELECT sqlc.embed(chirps), (
+    SELECT COUNT(*) FROM chirps AS replies
+    WHERE replies.in_reply_to = chirps.id
+) AS reply_count
+FROM chirps, root
+WHERE chirps.id = root.id OR chirps.thread_id = root.id
+ORDER BY chirps.created_at ASC, chirps.id ASC;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1,
+edit_count = edit_count + 1,
+updated_at = NOW()
+WHERE id = $2 AND user_id = $3
+RETURNING *;
+
+-- name: SearchChirps :many
+SELECT sqlc.embed(chirps),
+    (
+        ts_rank(chirps.search_vector, websearch_to_tsquery('english', sqlc.arg('query')), 32)
+        / (1 + GREATEST(EXTRACT(EPOCH FROM sqlc.arg('ranked_at')::timestamp - chirps.created_at), 0) / 86400)
+    )::real AS score
+FROM chirps
+WHERE chirps.search_vector @@ websearch_to_tsquery('english', sqlc.arg('query'))
+AND chirps.deleted_at IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('author_id')::uuid)
+AND (chirps.hidden_at IS NULL OR chirps.user_id = sqlc.narg('viewer_id')::uuid OR sqlc.arg('viewer_is_moderator')::boolean)
+AND chirps.user_id NOT IN (
+    SELECT muted_id FROM mutes WHERE muter_id = sqlc.narg('viewer_id')::uuid
+    UNION
+    SELECT blocked_id FROM blocks WHERE blocker_id = sqlc.narg('viewer_id')::uuid
+)
+AND (
+    sqlc.narg('cursor_score')::real IS NULL
+    OR (
+        (
+            ts_rank(chirps.search_vector, websearch_to_tsquery('english', sqlc.arg('query')), 32)
+            / (1 + GREATEST(EXTRACT(EPOCH FROM sqlc.arg('ranked_at')::timestamp - chirps.created_at), 0) / 86400)
+        )::real,
+        chirps.created_at,
+        chirps.id
+    ) < (sqlc.narg('cursor_score')::real, sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY score DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW()
+WHERE id = $1 AND hidden_at IS NULL;
+
+-- name: ListUserChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL
+ORDER BY created_at, id;
\ No newline at end of file
@@ -0,0 +1,27 @@
+-- name: UpsertEmailChange :one
+INSERT INTO email_changes (user_id, new_email, token_hash, expires_at, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW()
+)
+ON CONFLICT (user_id) DO UPDATE
+SET new_email = EXCLUDED.new_email,
+token_hash = EXCLUDED.token_hash,
+expires_at = EXCLUDED.expires_at,
+created_at = EXCLUDED.created_at
+RETURNING *;
+
+-- name: GetEmailChange :one
+SELECT * FROM email_changes
+WHERE user_id = $1 AND expires_at > NOW();
+
+-- name: GetEmailChangeByToken :one
+SELECT * FROM email_changes
+WHERE token_hash = $1 AND expires_at > NOW();
+
+-- name: DeleteEmailChange :exec
+DELETE FROM email_changes
+WHERE user_id = $1;
\ No newline at end of file
@@ -0,0 +1,21 @@
+-- name: CreateEmailVerification :exec
+INSERT INTO email_verifications (token_hash, user_id, email, expires_at, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW()
+);
+
+-- name: GetEmailVerification :one
+SELECT * FROM email_verifications
+WHERE token_hash = $1 AND expires_at > NOW();
+
+-- name: CountEmailVerificationsSince :one
+SELECT COUNT(*) FROM email_verifications
+WHERE user_id = $1 AND created_at > $2;
+
+-- name: DeleteUserEmailVerifications :exec
+DELETE FROM email_verifications
+WHERE user_id = $1;
\ No newline at end of file
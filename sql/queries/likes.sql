@@ -0,0 +1,37 @@
+-- name: LikeChirp :exec
+INSERT INTO likes (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: UnlikeChirp :exec
+DELETE FROM likes
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetChirpLikeStats :many
+SELECT chirp_id,
+    COUNT(*) AS like_count,
+    COALESCE(BOOL_OR(user_id = sqlc.narg('viewer_id')::uuid), false)::bool AS liked_by_me
+FROM likes
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+GROUP BY chirp_id;
+
+-- name: ListLikedChirps :many
+SELECT sqlc.embed(chirps), likes.created_at AS liked_at FROM chirps
+JOIN likes ON likes.chirp_id = chirps.id
+WHERE likes.user_id = sqlc.arg('user_id') AND chirps.deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR chirps.user_id = sqlc.narg('viewer_id')::uuid OR sqlc.arg('viewer_is_moderator')::boolean)
+AND chirps.user_id NOT IN (
+    SELECT muted_id FROM mutes WHERE muter_id = sqlc.narg('viewer_id')::uuid
+    UNION
+    SELECT blocked_id FROM blocks WHERE blocker_id = sqlc.narg('viewer_id')::uuid
+)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (likes.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY likes.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
\ No newline at end of file
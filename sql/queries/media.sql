@@ -0,0 +1,37 @@
+-- name: CreateMediaFile :one
+INSERT INTO media_files (id, user_id, content_type, width, height, size_bytes, blob_key, thumbnail_key, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    NOW()
+)
+RETURNING *;
+
+-- name: GetMediaFilesByIDs :many
+SELECT * FROM media_files
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: AttachChirpMedia :exec
+INSERT INTO chirp_attachments (chirp_id, media_id, position)
+VALUES (
+    $1,
+    $2,
+    $3
+);
+
+-- name: GetChirpMedia :many
+SELECT sqlc.embed(media_files), chirp_attachments.chirp_id FROM chirp_attachments
+JOIN media_files ON media_files.id = chirp_attachments.media_id
+WHERE chirp_attachments.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_attachments.chirp_id, chirp_attachments.position;
+
+-- name: ListUserMediaFiles :many
+SELECT * FROM media_files
+WHERE user_id = $1
+ORDER BY created_at, id;
\ No newline at end of file
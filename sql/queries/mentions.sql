@@ -0,0 +1,35 @@
+-- name: CreateMention :exec
+INSERT INTO mentions (chirp_id, user_id, start_offset, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW()
+);
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM mentions
+WHERE chirp_id = $1;
+
+-- name: GetChirpMentions :many
+SELECT mentions.chirp_id, mentions.user_id, users.handle, mentions.start_offset FROM mentions
+JOIN users ON users.id = mentions.user_id
+WHERE mentions.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY mentions.chirp_id, mentions.start_offset;
+
+-- name: ListMentionChirps :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND id IN (SELECT chirp_id FROM mentions WHERE mentions.user_id = sqlc.arg('user_id'))
+AND (hidden_at IS NULL OR sqlc.arg('viewer_is_moderator')::boolean)
+AND user_id NOT IN (
+    SELECT muted_id FROM mutes WHERE muter_id = sqlc.arg('user_id')
+    UNION
+    SELECT blocked_id FROM blocks WHERE blocker_id = sqlc.arg('user_id')
+)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
\ No newline at end of file
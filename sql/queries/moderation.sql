@@ -0,0 +1,62 @@
+-- name: ListModerationWords :many
+SELECT * FROM moderation_words
+ORDER BY word ASC;
+
+-- name: UpsertModerationWord :one
+INSERT INTO moderation_words (word, action, created_at, updated_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW()
+)
+ON CONFLICT (word) DO UPDATE
+SET action = EXCLUDED.action,
+updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteModerationWord :execrows
+DELETE FROM moderation_words
+WHERE word = $1;
+
+-- name: CreateChirpFlag :exec
+INSERT INTO chirp_flags (id, chirp_id, reasons, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    NOW()
+);
+
+-- name: ListOpenChirpFlags :many
+SELECT * FROM chirp_flags
+WHERE resolved_at IS NULL
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ResolveChirpFlags :exec
+UPDATE chirp_flags
+SET resolved_at = NOW()
+WHERE chirp_id = $1 AND resolved_at IS NULL;
+
+-- name: CreateModerationAction :exec
+INSERT INTO moderation_actions (id, chirp_id, chirp_body, report_id, moderator_id, action, note, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    NOW()
+);
+
+-- name: ListChirpModerationActions :many
+SELECT * FROM moderation_actions
+WHERE chirp_id = sqlc.arg('chirp_id')::uuid
+ORDER BY created_at ASC;
\ No newline at end of file
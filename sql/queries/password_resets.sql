@@ -0,0 +1,24 @@
+-- name: CreatePasswordReset :exec
+INSERT INTO password_resets (token_hash, user_id, expires_at, used_at, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NULL,
+    NOW()
+);
+
+-- name: CountPasswordResetsSince :one
+SELECT COUNT(*) FROM password_resets
+WHERE user_id = $1 AND created_at > $2;
+
+-- name: UsePasswordReset :one
+UPDATE password_resets
+SET used_at = NOW()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: ExpireUserPasswordResets :exec
+UPDATE password_resets
+SET used_at = NOW()
+WHERE user_id = $1 AND used_at IS NULL;
\ No newline at end of file
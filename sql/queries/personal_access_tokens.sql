@@ -0,0 +1,34 @@
+-- name: CreatePersonalAccessToken :one
+INSERT INTO personal_access_tokens (id, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked_at, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    NULL,
+    NULL,
+    NOW()
+)
+RETURNING *;
+
+-- name: ListUserPersonalAccessTokens :many
+SELECT * FROM personal_access_tokens
+WHERE user_id = $1 AND revoked_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: CountUserPersonalAccessTokens :one
+SELECT COUNT(*) FROM personal_access_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW();
+
+-- name: RevokePersonalAccessToken :execrows
+UPDATE personal_access_tokens
+SET revoked_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: UsePersonalAccessToken :one
+UPDATE personal_access_tokens
+SET last_used_at = NOW()
+WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+RETURNING id, user_id, scopes;
\ No newline at end of file
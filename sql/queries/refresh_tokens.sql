@@ -1,27 +1,72 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens(token, created_at, updated_at, user_id, expires_at, revoked_at)
+INSERT INTO refresh_tokens(token_hash, created_at, updated_at, user_id, family_id, expires_at, revoked_at, rotated_at, device_name, user_agent, ip_address, last_used_at)
 VALUES (
     $1,
     NOW(),
     NOW(),
     $2,
     $3,
-    NULL
+    $4,
+    NULL,
+    NULL,
+    $5,
+    $6,
+    $7,
+    NOW()
 )
 RETURNING *;
 
 -- name: GetRefreshToken :one
 SELECT * FROM refresh_tokens
-WHERE token = $1;
+WHERE token_hash = $1;
 
--- name: GetUserFromRefreshToken :one
-SELECT users.* FROM users
-JOIN refresh_tokens ON users.id = refresh_tokens.user_id
-WHERE refresh_tokens.token = $1 AND refresh_tokens.expires_at > $2 AND refresh_tokens.revoked_at IS NULL;
+-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET rotated_at = NOW(),
+updated_at = NOW()
+WHERE token_hash = $1 AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > NOW();
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
 
--- name: RevokeRefreshToken :one
+-- name: RevokeUserRefreshTokens :exec
 UPDATE refresh_tokens
-SET expires_at = NOW(),
+SET revoked_at = NOW(),
 updated_at = NOW()
-WHERE token = $1
-RETURNING *;
\ No newline at end of file
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: ListUserSessions :many
+SELECT refresh_tokens.family_id, refresh_tokens.device_name, refresh_tokens.user_agent, refresh_tokens.ip_address,
+refresh_tokens.last_used_at, refresh_tokens.expires_at, (
+    SELECT MIN(family.created_at) FROM refresh_tokens AS family
+    WHERE family.family_id = refresh_tokens.family_id
+)::timestamp AS signed_in_at
+FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND rotated_at IS NULL AND expires_at > NOW()
+ORDER BY last_used_at DESC;
+
+-- name: IsSessionActive :one
+SELECT EXISTS (
+    SELECT 1 FROM refresh_tokens
+    WHERE family_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+)::bool AS active;
+
+-- name: RevokeUserSession :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE user_id = $1 AND family_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeOtherUserSessions :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL;
+
+-- name: ListUserRefreshTokens :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at;
\ No newline at end of file
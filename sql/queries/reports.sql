@@ -0,0 +1,37 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, chirp_id, reporter_id, reason, details, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW()
+)
+RETURNING *;
+
+-- name: GetReport :one
+SELECT * FROM reports
+WHERE id = $1;
+
+-- name: ListOpenReports :many
+SELECT * FROM reports
+WHERE status = 'open'
+AND chirp_id IS NOT NULL
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListChirpReports :many
+SELECT * FROM reports
+WHERE chirp_id = sqlc.arg('chirp_id')::uuid
+ORDER BY created_at ASC;
+
+-- name: CloseChirpReports :exec
+UPDATE reports
+SET status = sqlc.arg('status'),
+resolved_at = NOW()
+WHERE chirp_id = sqlc.arg('chirp_id')::uuid AND status = 'open';
\ No newline at end of file
@@ -0,0 +1,50 @@
+-- name: UpsertTag :one
+INSERT INTO tags (id, name, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    NOW()
+)
+ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+RETURNING *;
+
+-- name: AddChirpTag :exec
+INSERT INTO chirp_tags (chirp_id, tag_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (chirp_id, tag_id) DO NOTHING;
+
+-- name: DeleteChirpTags :exec
+DELETE FROM chirp_tags
+WHERE chirp_id = $1;
+
+-- name: ListTagChirps :many
+SELECT chirps.* FROM chirps
+JOIN chirp_tags ON chirp_tags.chirp_id = chirps.id
+JOIN tags ON tags.id = chirp_tags.tag_id
+WHERE tags.name = sqlc.arg('name') AND chirps.deleted_at IS NULL
+AND (chirps.hidden_at IS NULL OR chirps.user_id = sqlc.narg('viewer_id')::uuid OR sqlc.arg('viewer_is_moderator')::boolean)
+AND chirps.user_id NOT IN (
+    SELECT muted_id FROM mutes WHERE muter_id = sqlc.narg('viewer_id')::uuid
+    UNION
+    SELECT blocked_id FROM blocks WHERE blocker_id = sqlc.narg('viewer_id')::uuid
+)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListTrendingTags :many
+SELECT tags.name, COUNT(*) AS chirp_count FROM chirp_tags
+JOIN tags ON tags.id = chirp_tags.tag_id
+JOIN chirps ON chirps.id = chirp_tags.chirp_id
+WHERE chirps.created_at > NOW() - (sqlc.arg('window_seconds')::int * INTERVAL '1 second')
+AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+GROUP BY tags.name
+ORDER BY chirp_count DESC, tags.name ASC
+LIMIT sqlc.arg('page_limit');
\ No newline at end of file
@@ -0,0 +1,84 @@
+-- name: UpsertTotpCredential :one
+INSERT INTO totp_credentials (user_id, secret, enabled_at, last_used_step, created_at, updated_at)
+VALUES (
+    $1,
+    $2,
+    NULL,
+    0,
+    NOW(),
+    NOW()
+)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret,
+last_used_step = 0,
+updated_at = NOW()
+WHERE totp_credentials.enabled_at IS NULL
+RETURNING *;
+
+-- name: GetTotpCredential :one
+SELECT * FROM totp_credentials
+WHERE user_id = $1;
+
+-- name: EnableTotpCredential :execrows
+UPDATE totp_credentials
+SET enabled_at = NOW(),
+last_used_step = $2,
+updated_at = NOW()
+WHERE user_id = $1 AND enabled_at IS NULL;
+
+-- name: UseTotpStep :execrows
+UPDATE totp_credentials
+SET last_used_step = $2,
+updated_at = NOW()
+WHERE user_id = $1 AND enabled_at IS NOT NULL AND last_used_step < $2;
+
+-- name: DeleteTotpCredential :exec
+DELETE FROM totp_credentials
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (user_id, code_hash, used_at, created_at)
+VALUES (
+    $1,
+    $2,
+    NULL,
+    NOW()
+);
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM recovery_codes
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: DeleteUserRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
+
+-- name: CreateTwoFactorChallenge :exec
+INSERT INTO two_factor_challenges (token_hash, user_id, device_name, attempts, expires_at, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    0,
+    $4,
+    NOW()
+);
+
+-- name: ClaimTwoFactorAttempt :one
+UPDATE two_factor_challenges
+SET attempts = attempts + 1
+WHERE token_hash = $1 AND expires_at > NOW() AND attempts < $2
+RETURNING *;
+
+-- name: DeleteTwoFactorChallenge :execrows
+DELETE FROM two_factor_challenges
+WHERE token_hash = $1;
+
+-- name: DeleteExpiredTwoFactorChallenges :exec
+DELETE FROM two_factor_challenges
+WHERE expires_at <= NOW();
\ No newline at end of file
@@ -1,11 +1,12 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
@@ -19,12 +20,140 @@ DELETE FROM users;
 -- name: UpdateUser :one
 UPDATE users
 SET updated_at = NOW(),
-email = $1,
-hashed_password = $2
-WHERE id = $3
+email_verified_at = CASE WHEN email = sqlc.arg('email') THEN email_verified_at END,
+email = sqlc.arg('email'),
+hashed_password = sqlc.arg('hashed_password'),
+handle = COALESCE(sqlc.narg('handle'), handle)
+WHERE id = sqlc.arg('id')
 RETURNING *;
 
 -- name: SetUserChirpyRed :exec
 UPDATE users
 SET is_chirpy_red = true
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: GetUsersByHandles :many
+SELECT id, handle FROM users
+WHERE handle = ANY(sqlc.arg('handles')::text[])
+AND id NOT IN (SELECT blocker_id FROM blocks WHERE blocked_id = sqlc.arg('author_id'));
+
+-- name: SuspendUser :execrows
+UPDATE users
+SET suspended_until = $1,
+suspension_reason = $2,
+updated_at = NOW()
+WHERE id = $3;
+
+-- name: BanUser :execrows
+UPDATE users
+SET banned = true,
+suspension_reason = $1,
+updated_at = NOW()
+WHERE id = $2;
+
+-- name: UnsuspendUser :execrows
+UPDATE users
+SET suspended_until = NULL,
+banned = false,
+suspension_reason = '',
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetUserStanding :one
+SELECT banned, suspended_until, deletion_requested_at FROM users
+WHERE id = $1;
+
+-- name: ListSuspendedUsers :many
+SELECT * FROM users
+WHERE (banned OR suspended_until > NOW())
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: GrantUserRole :execrows
+UPDATE users
+SET roles = CASE
+    WHEN sqlc.arg('role')::text = ANY(roles) THEN roles
+    ELSE array_append(roles, sqlc.arg('role')::text)
+END,
+updated_at = NOW()
+WHERE id = sqlc.arg('id');
+
+-- name: RevokeUserRole :execrows
+UPDATE users
+SET roles = array_remove(roles, sqlc.arg('role')::text),
+updated_at = NOW()
+WHERE id = sqlc.arg('id');
+
+-- name: GetUserProfile :one
+SELECT id, created_at, handle, is_chirpy_red, display_name, bio, location, website, avatar_url,
+    (SELECT COUNT(*) FROM follows WHERE followee_id = users.id) AS follower_count,
+    (SELECT COUNT(*) FROM follows WHERE follower_id = users.id) AS following_count
+FROM users
+WHERE id = $1;
+
+-- name: GetChirpAuthors :many
+SELECT id, handle, display_name, avatar_url FROM users
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET updated_at = NOW(),
+handle = COALESCE(sqlc.narg('handle'), handle),
+display_name = COALESCE(sqlc.narg('display_name'), display_name),
+bio = COALESCE(sqlc.narg('bio'), bio),
+location = COALESCE(sqlc.narg('location'), location),
+website = COALESCE(sqlc.narg('website'), website),
+avatar_url = COALESCE(sqlc.narg('avatar_url'), avatar_url)
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: UpdateUserEmail :one
+UPDATE users
+SET updated_at = NOW(),
+email = $1,
+email_verified_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET updated_at = NOW(),
+hashed_password = $1
+WHERE id = $2;
+
+-- name: MarkUserEmailVerified :one
+UPDATE users
+SET updated_at = NOW(),
+email_verified_at = COALESCE(email_verified_at, NOW())
+WHERE id = $1 AND email = $2
+RETURNING *;
+
+-- name: RequestUserDeletion :exec
+UPDATE users
+SET updated_at = NOW(),
+deletion_requested_at = NOW()
+WHERE id = $1;
+
+-- name: CancelUserDeletion :exec
+UPDATE users
+SET updated_at = NOW(),
+deletion_requested_at = NULL
+WHERE id = $1 AND deletion_requested_at IS NOT NULL;
+
+-- name: ListUsersDueForDeletion :many
+SELECT id FROM users
+WHERE deletion_requested_at <= $1
+ORDER BY deletion_requested_at
+LIMIT $2;
+
+-- name: DeleteUserDueForDeletion :execrows
+DELETE FROM users
+WHERE id = $1 AND deletion_requested_at <= $2;
\ No newline at end of file
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN in_reply_to UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN thread_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_chirps_in_reply_to ON chirps (in_reply_to);
+CREATE INDEX idx_chirps_thread_id ON chirps (thread_id, created_at, id);
+
+-- +goose Down
+DROP INDEX idx_chirps_thread_id;
+DROP INDEX idx_chirps_in_reply_to;
+
+ALTER TABLE chirps
+DROP COLUMN deleted_at,
+DROP COLUMN thread_id,
+DROP COLUMN in_reply_to;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE likes(
+    user_id UUID NOT NULL,
+    chirp_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id),
+    CONSTRAINT fk_users
+    FOREIGN KEY(user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_chirps
+    FOREIGN KEY(chirp_id) REFERENCES chirps(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idx_likes_chirp_id ON likes (chirp_id);
+CREATE INDEX idx_likes_user_id_created_at ON likes (user_id, created_at);
+
+-- +goose Down
+DROP TABLE likes;
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN rechirp_of UUID REFERENCES chirps(id) ON DELETE CASCADE,
+ADD COLUMN quote_of UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD CONSTRAINT rechirp_or_quote CHECK (rechirp_of IS NULL OR quote_of IS NULL);
+
+CREATE UNIQUE INDEX idx_chirps_user_id_rechirp_of ON chirps (user_id, rechirp_of)
+WHERE rechirp_of IS NOT NULL;
+CREATE INDEX idx_chirps_quote_of ON chirps (quote_of);
+
+-- +goose Down
+DROP INDEX idx_chirps_quote_of;
+DROP INDEX idx_chirps_user_id_rechirp_of;
+
+ALTER TABLE chirps
+DROP CONSTRAINT rechirp_or_quote,
+DROP COLUMN quote_of,
+DROP COLUMN rechirp_of;
\ No newline at end of file
@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN edit_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE chirp_revisions(
+    id UUID PRIMARY KEY,
+    chirp_id UUID NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_chirps
+    FOREIGN KEY(chirp_id) REFERENCES chirps(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idx_chirp_revisions_chirp_id ON chirp_revisions (chirp_id, created_at);
+
+-- +goose Down
+DROP TABLE chirp_revisions;
+
+ALTER TABLE chirps
+DROP COLUMN edit_count;
\ No newline at end of file
@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN handle TEXT UNIQUE;
+
+CREATE TABLE mentions(
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    start_offset INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, start_offset),
+    CONSTRAINT fk_chirps
+    FOREIGN KEY(chirp_id) REFERENCES chirps(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_users
+    FOREIGN KEY(user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idx_mentions_user_id ON mentions (user_id);
+
+-- +goose Down
+DROP TABLE mentions;
+
+ALTER TABLE users
+DROP COLUMN handle;
\ No newline at end of file
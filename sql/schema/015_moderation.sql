@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE moderation_words(
+    word TEXT PRIMARY KEY,
+    action TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    CONSTRAINT valid_action CHECK (action IN ('mask', 'flag', 'reject'))
+);
+
+INSERT INTO moderation_words (word, action, created_at, updated_at)
+VALUES
+    ('kerfuffle', 'mask', NOW(), NOW()),
+    ('sharbert', 'mask', NOW(), NOW()),
+    ('fornax', 'mask', NOW(), NOW());
+
+CREATE TABLE chirp_flags(
+    id UUID PRIMARY KEY,
+    chirp_id UUID NOT NULL,
+    reasons TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP,
+    CONSTRAINT fk_chirps
+    FOREIGN KEY(chirp_id) REFERENCES chirps(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idx_chirp_flags_open ON chirp_flags (created_at)
+WHERE resolved_at IS NULL;
+
+-- +goose Down
+DROP TABLE chirp_flags;
+DROP TABLE moderation_words;
\ No newline at end of file
@@ -0,0 +1,54 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN hidden_at TIMESTAMP;
+
+ALTER TABLE users
+ADD COLUMN suspended_until TIMESTAMP;
+
+CREATE TABLE reports(
+    id UUID PRIMARY KEY,
+    chirp_id UUID NOT NULL,
+    reporter_id UUID NOT NULL,
+    reason TEXT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open',
+    created_at TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP,
+    CONSTRAINT fk_chirps
+    FOREIGN KEY(chirp_id) REFERENCES chirps(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_users
+    FOREIGN KEY(reporter_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT valid_status CHECK (status IN ('open', 'resolved', 'dismissed'))
+);
+
+CREATE UNIQUE INDEX idx_reports_open_per_reporter ON reports (chirp_id, reporter_id)
+WHERE status = 'open';
+CREATE INDEX idx_reports_open ON reports (created_at, id)
+WHERE status = 'open';
+
+CREATE TABLE moderation_actions(
+    id UUID PRIMARY KEY,
+    chirp_id UUID NOT NULL,
+    report_id UUID REFERENCES reports(id) ON DELETE SET NULL,
+    moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_chirps
+    FOREIGN KEY(chirp_id) REFERENCES chirps(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idx_moderation_actions_chirp_id ON moderation_actions (chirp_id, created_at);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+
+ALTER TABLE users
+DROP COLUMN suspended_until;
+
+ALTER TABLE chirps
+DROP COLUMN hidden_at;
\ No newline at end of file
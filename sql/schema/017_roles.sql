@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN roles TEXT[] NOT NULL DEFAULT '{}',
+ADD CONSTRAINT valid_roles CHECK (roles <@ ARRAY['admin', 'moderator']::TEXT[]);
+
+-- +goose Down
+ALTER TABLE users
+DROP CONSTRAINT valid_roles,
+DROP COLUMN roles;
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN banned BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN suspension_reason TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_users_suspended ON users (created_at, id)
+WHERE banned OR suspended_until IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_users_suspended;
+
+ALTER TABLE users
+DROP COLUMN suspension_reason,
+DROP COLUMN banned;
\ No newline at end of file
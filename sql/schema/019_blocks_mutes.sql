@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE blocks(
+    blocker_id UUID NOT NULL,
+    blocked_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CONSTRAINT fk_blocker
+    FOREIGN KEY(blocker_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_blocked
+    FOREIGN KEY(blocked_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT no_self_block CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX idx_blocks_blocked_id ON blocks (blocked_id);
+
+CREATE TABLE mutes(
+    muter_id UUID NOT NULL,
+    muted_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (muter_id, muted_id),
+    CONSTRAINT fk_muter
+    FOREIGN KEY(muter_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_muted
+    FOREIGN KEY(muted_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT no_self_mute CHECK (muter_id <> muted_id)
+);
+
+-- +goose Down
+DROP TABLE mutes;
+DROP TABLE blocks;
\ No newline at end of file
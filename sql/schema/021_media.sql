@@ -0,0 +1,36 @@
+-- +goose Up
+CREATE TABLE media_files(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    content_type TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    blob_key TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_user
+    FOREIGN KEY(user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idx_media_files_user_id ON media_files (user_id);
+
+CREATE TABLE chirp_attachments(
+    chirp_id UUID NOT NULL,
+    media_id UUID NOT NULL,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (chirp_id, media_id),
+    CONSTRAINT fk_chirp
+    FOREIGN KEY(chirp_id) REFERENCES chirps(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_media
+    FOREIGN KEY(media_id) REFERENCES media_files(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idx_chirp_attachments_media_id ON chirp_attachments (media_id);
+
+-- +goose Down
+DROP TABLE chirp_attachments;
+DROP TABLE media_files;
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE email_changes(
+    user_id UUID PRIMARY KEY,
+    new_email TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_user
+    FOREIGN KEY(user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE email_changes;
\ No newline at end of file
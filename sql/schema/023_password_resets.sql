@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE password_resets(
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_user
+    FOREIGN KEY(user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idx_password_resets_user_id ON password_resets (user_id);
+
+-- +goose Down
+DROP TABLE password_resets;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN deletion_requested_at TIMESTAMP;
+
+CREATE INDEX idx_users_deletion_requested_at ON users (deletion_requested_at)
+WHERE deletion_requested_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_users_deletion_requested_at;
+ALTER TABLE users DROP COLUMN deletion_requested_at;
\ No newline at end of file
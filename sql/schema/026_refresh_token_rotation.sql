@@ -0,0 +1,38 @@
+-- +goose Up
+-- Tokens are only kept as their SHA-256, so a leaked table can't be used
+-- to sign in. Existing tokens are hashed in place and stay valid.
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+UPDATE refresh_tokens SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+-- Every login starts a family; each refresh rotates to a new token in the
+-- same family and marks the old one rotated.
+ALTER TABLE refresh_tokens ADD COLUMN family_id UUID;
+UPDATE refresh_tokens SET family_id = gen_random_uuid();
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+ALTER TABLE refresh_tokens ADD COLUMN rotated_at TIMESTAMP;
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+
+CREATE TABLE security_events(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    event TEXT NOT NULL,
+    details TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    user_agent TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_user
+    FOREIGN KEY(user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idx_security_events_user_id ON security_events (user_id, created_at);
+
+-- +goose Down
+DROP TABLE security_events;
+DROP INDEX idx_refresh_tokens_family_id;
+ALTER TABLE refresh_tokens DROP COLUMN rotated_at;
+ALTER TABLE refresh_tokens DROP COLUMN family_id;
+-- Hashes can't be turned back into tokens, so everyone is signed out.
+DELETE FROM refresh_tokens;
+ALTER TABLE refresh_tokens RENAME COLUMN token_hash TO token;
\ No newline at end of file
@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE refresh_tokens ADD COLUMN device_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN last_used_at TIMESTAMP;
+UPDATE refresh_tokens SET last_used_at = updated_at;
+ALTER TABLE refresh_tokens ALTER COLUMN last_used_at SET NOT NULL;
+
+-- The old /api/revoke only moved expires_at up to the moment of revocation,
+-- together with updated_at. Record those as revoked so audits see them.
+UPDATE refresh_tokens SET revoked_at = expires_at
+WHERE revoked_at IS NULL AND expires_at = updated_at;
+
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens (user_id)
+WHERE revoked_at IS NULL AND rotated_at IS NULL;
+
+-- +goose Down
+DROP INDEX idx_refresh_tokens_user_id;
+ALTER TABLE refresh_tokens DROP COLUMN last_used_at;
+ALTER TABLE refresh_tokens DROP COLUMN ip_address;
+ALTER TABLE refresh_tokens DROP COLUMN user_agent;
+ALTER TABLE refresh_tokens DROP COLUMN device_name;
\ No newline at end of file
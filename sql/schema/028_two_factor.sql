@@ -0,0 +1,45 @@
+-- +goose Up
+CREATE TABLE totp_credentials(
+    user_id UUID PRIMARY KEY,
+    secret TEXT NOT NULL,
+    -- enabled_at stays NULL until enrollment is confirmed with a code.
+    enabled_at TIMESTAMP,
+    -- last_used_step is the last TOTP time step accepted, so a code can
+    -- only be used once.
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_user
+    FOREIGN KEY(user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes(
+    user_id UUID NOT NULL,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, code_hash),
+    CONSTRAINT fk_user
+    FOREIGN KEY(user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE two_factor_challenges(
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID NOT NULL,
+    device_name TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_user
+    FOREIGN KEY(user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX idx_two_factor_challenges_user_id ON two_factor_challenges (user_id);
+
+-- +goose Down
+DROP TABLE two_factor_challenges;
+DROP TABLE recovery_codes;
+DROP TABLE totp_credentials;
\ No newline at end of file
@@ -0,0 +1,44 @@
+-- +goose Up
+-- Reports and moderation decisions outlive the chirps they are about, so
+-- deleting a chirp no longer erases the moderation history.
+ALTER TABLE reports
+ALTER COLUMN chirp_id DROP NOT NULL,
+DROP CONSTRAINT fk_chirps,
+ADD CONSTRAINT fk_chirps
+FOREIGN KEY(chirp_id) REFERENCES chirps(id)
+ON DELETE SET NULL;
+
+ALTER TABLE moderation_actions
+ALTER COLUMN chirp_id DROP NOT NULL,
+DROP CONSTRAINT fk_chirps,
+ADD CONSTRAINT fk_chirps
+FOREIGN KEY(chirp_id) REFERENCES chirps(id)
+ON DELETE SET NULL,
+ADD COLUMN chirp_body TEXT NOT NULL DEFAULT '';
+
+UPDATE moderation_actions
+SET chirp_body = chirps.body
+FROM chirps
+WHERE chirps.id = moderation_actions.chirp_id;
+
+-- +goose Down
+DELETE FROM moderation_actions
+WHERE chirp_id IS NULL;
+
+DELETE FROM reports
+WHERE chirp_id IS NULL;
+
+ALTER TABLE moderation_actions
+DROP COLUMN chirp_body,
+DROP CONSTRAINT fk_chirps,
+ADD CONSTRAINT fk_chirps
+FOREIGN KEY(chirp_id) REFERENCES chirps(id)
+ON DELETE CASCADE,
+ALTER COLUMN chirp_id SET NOT NULL;
+
+ALTER TABLE reports
+DROP CONSTRAINT fk_chirps,
+ADD CONSTRAINT fk_chirps
+FOREIGN KEY(chirp_id) REFERENCES chirps(id)
+ON DELETE CASCADE,
+ALTER COLUMN chirp_id SET NOT NULL;
\ No newline at end of file
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+var (
+	errAccountSuspended = errors.New("account is suspended")
+	errAccountBanned    = errors.New("account is banned")
+	// errDeletionPending rejects access tokens of accounts waiting to be
+	// purged. Logging in again cancels the deletion.
+	errDeletionPending = errors.New("account is scheduled for deletion, log in again to keep it")
+)
+
+// checkStanding returns an error when the account may not be used right
+// now, either because it is banned or because a suspension is running.
+func checkStanding(banned bool, suspendedUntil sql.NullTime) error {
+	if banned {
+		return errAccountBanned
+	}
+	if suspendedUntil.Valid && suspendedUntil.Time.After(time.Now()) {
+		return fmt.Errorf("%w until %s", errAccountSuspended, suspendedUntil.Time.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// respondWithAuthError answers a failed authentication. Suspended, banned
+// and deleted accounts get 403 with the reason, everything else 401.
+func respondWithAuthError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errAccountSuspended) || errors.Is(err, errAccountBanned) || errors.Is(err, errDeletionPending) {
+		respondWithError(w, http.StatusForbidden, "Your "+err.Error(), err)
+		return
+	}
+	if errors.Is(err, errScopeNotAllowed) || errors.Is(err, errMissingScope) {
+		respondWithError(w, http.StatusForbidden, err.Error(), err)
+		return
+	}
+	if errors.Is(err, errInvalidAccessToken) {
+		respondWithError(w, http.StatusUnauthorized, err.Error(), err)
+		return
+	}
+	respondWithError(w, http.StatusUnauthorized, "Malformed or missing access token", err)
+}
+
+// suspendUser suspends a user until the given time and signs them out
+// everywhere by revoking their refresh tokens.
+func suspendUser(ctx context.Context, q *database.Queries, userID uuid.UUID, until time.Time, reason string) error {
+	updated, err := q.SuspendUser(ctx, database.SuspendUserParams{
+		SuspendedUntil:   sql.NullTime{Time: until, Valid: true},
+		SuspensionReason: reason,
+		ID:               userID,
+	})
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+	return q.RevokeUserRefreshTokens(ctx, userID)
+}
+
+// banUser is suspendUser without an end date.
+func banUser(ctx context.Context, q *database.Queries, userID uuid.UUID, reason string) error {
+	updated, err := q.BanUser(ctx, database.BanUserParams{
+		SuspensionReason: reason,
+		ID:               userID,
+	})
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+	return q.RevokeUserRefreshTokens(ctx, userID)
+}
+
+type suspendedUserResponse struct {
+	Id               uuid.UUID  `json:"id"`
+	Email            string     `json:"email"`
+	Handle           string     `json:"handle,omitempty"`
+	Banned           bool       `json:"banned"`
+	Suspended_until  *time.Time `json:"suspended_until,omitempty"`
+	SuspensionReason string     `json:"suspension_reason"`
+}
+
+func newSuspendedUserResponse(user database.User) suspendedUserResponse {
+	return suspendedUserResponse{
+		Id:               user.ID,
+		Email:            user.Email,
+		Handle:           user.Handle.String,
+		Banned:           user.Banned,
+		Suspended_until:  nullTimePtr(user.SuspendedUntil),
+		SuspensionReason: user.SuspensionReason,
+	}
+}
+
+// handlerSuspendUser suspends a user for a number of days, or bans them
+// when permanent is set.
+func (cfg *apiConfig) handlerSuspendUser(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Days      int    `json:"days"`
+		Permanent bool   `json:"permanent"`
+		Reason    string `json:"reason"`
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	if params.Reason == "" {
+		respondWithError(w, http.StatusBadRequest, "A reason is required", nil)
+		return
+	}
+	if !params.Permanent && (params.Days < 1 || params.Days > maxSuspensionDays) {
+		respondWithError(w, http.StatusBadRequest, "days must be between 1 and 365", nil)
+		return
+	}
+
+	if requestUserID(r) == (uuid.NullUUID{UUID: userID, Valid: true}) {
+		respondWithError(w, http.StatusBadRequest, "You can not suspend yourself", nil)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User does not exist", err)
+		return
+	}
+	// Moderators must not be able to lock out the admins above them.
+	if hasRole(user.Roles, roleAdmin) && !hasRole(requestClaims(r).Roles, roleAdmin) {
+		respondWithError(w, http.StatusForbidden, "Only admins can suspend admins", nil)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while suspending user", err)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+	if params.Permanent {
+		err = banUser(r.Context(), qtx, user.ID, params.Reason)
+	} else {
+		err = suspendUser(r.Context(), qtx, user.ID, time.Now().AddDate(0, 0, params.Days), params.Reason)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while suspending user", err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// handlerUnsuspendUser lifts a suspension or ban. Refresh tokens revoked by
+// the suspension stay revoked, so the user has to log in again.
+func (cfg *apiConfig) handlerUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	updated, err := cfg.dbQueries.UnsuspendUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while lifting suspension", err)
+		return
+	}
+	if updated == 0 {
+		respondWithError(w, http.StatusNotFound, "User does not exist", nil)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerListSuspendedUsers(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	users, err := cfg.dbQueries.ListSuspendedUsers(r.Context(), database.ListSuspendedUsersParams{
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageLimit:       page.queryLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting suspended users", err)
+		return
+	}
+
+	users, nextCursor := trimPage(users, page.Limit, func(user database.User) pageCursor {
+		return pageCursor{CreatedAt: user.CreatedAt, ID: user.ID}
+	})
+
+	response := make([]suspendedUserResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, newSuspendedUserResponse(user))
+	}
+
+	setNextPageHeaders(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxTagLength          = 50
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 30 * 24 * time.Hour
+	defaultTrendingLimit  = 10
+)
+
+// extractHashtags returns the distinct, lower cased tags in body without
+// their leading '#'. A tag must start at a word boundary and contain at
+// least one letter, so "#1" and "a#b" are not tags.
+func extractHashtags(body string) []string {
+	var tags []string
+	seen := map[string]bool{}
+
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '#' || (i > 0 && isTagRune(runes[i-1])) {
+			continue
+		}
+
+		end := i + 1
+		hasLetter := false
+		for end < len(runes) && isTagRune(runes[end]) {
+			hasLetter = hasLetter || unicode.IsLetter(runes[end])
+			end++
+		}
+
+		tag := normalizeTag(string(runes[i+1 : end]))
+		if hasLetter && len(tag) <= maxTagLength && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+		i = end - 1
+	}
+	return tags
+}
+
+func isTagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+// saveChirpTags replaces the tags of a chirp with the ones found in body.
+// It is meant to run inside the transaction that writes the chirp.
+func saveChirpTags(ctx context.Context, q *database.Queries, chirpID uuid.UUID, body string) error {
+	err := q.DeleteChirpTags(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range extractHashtags(body) {
+		tag, err := q.UpsertTag(ctx, name)
+		if err != nil {
+			return err
+		}
+		err = q.AddChirpTag(ctx, database.AddChirpTagParams{
+			ChirpID: chirpID,
+			TagID:   tag.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerGetTagChirps(w http.ResponseWriter, r *http.Request) {
+	tag := normalizeTag(r.PathValue("tag"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid tag", nil)
+		return
+	}
+
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	v := cfg.viewer(r)
+	chirps, err := cfg.dbQueries.ListTagChirps(r.Context(), database.ListTagChirpsParams{
+		Name:              tag,
+		ViewerID:          v.ID,
+		ViewerIsModerator: v.Moderator,
+		CursorCreatedAt:   page.cursorCreatedAt(),
+		CursorID:          page.cursorID(),
+		PageLimit:         page.queryLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting chirps", err)
+		return
+	}
+
+	chirps, nextCursor := trimPage(chirps, page.Limit, chirpCursor)
+
+	response := newChirpResponses(chirps)
+	err = cfg.decorateChirps(r.Context(), response, v)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting chirp details", err)
+		return
+	}
+
+	setNextPageHeaders(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerGetTrendingTags(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	window := defaultTrendingWindow
+	if windowString := query.Get("window"); windowString != "" {
+		parsed, err := time.ParseDuration(windowString)
+		if err != nil || parsed < time.Minute || parsed > maxTrendingWindow {
+			respondWithError(w, http.StatusBadRequest, "window must be a duration between 1m and 720h", err)
+			return
+		}
+		window = parsed
+	}
+
+	limit := defaultTrendingLimit
+	if limitString := query.Get("limit"); limitString != "" {
+		parsed, err := strconv.Atoi(limitString)
+		if err != nil || parsed < 1 || parsed > maxPageLimit {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = parsed
+	}
+
+	trending, err := cfg.dbQueries.ListTrendingTags(r.Context(), database.ListTrendingTagsParams{
+		WindowSeconds: int32(window / time.Second),
+		PageLimit:     int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting trending tags", err)
+		return
+	}
+
+	type returnVals struct {
+		Tag         string `json:"tag"`
+		Chirp_count int64  `json:"chirp_count"`
+	}
+
+	response := make([]returnVals, 0, len(trending))
+	for _, t := range trending {
+		response = append(response, returnVals{
+			Tag:         t.Name,
+			Chirp_count: t.ChirpCount,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
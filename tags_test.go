@@ -0,0 +1,89 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "Tags between words",
+			body: "Loving #Go and #golang today",
+			want: []string{"go", "golang"},
+		},
+		{
+			name: "Trailing punctuation",
+			body: "#go, #rust. (#zig) #c!",
+			want: []string{"go", "rust", "zig", "c"},
+		},
+		{
+			name: "Case is folded",
+			body: "#GoLang",
+			want: []string{"golang"},
+		},
+		{
+			name: "Duplicates are dropped",
+			body: "#go #Go #GO #go",
+			want: []string{"go"},
+		},
+		{
+			name: "Digits only",
+			body: "#1 and #2024",
+			want: nil,
+		},
+		{
+			name: "Digits after a letter",
+			body: "#go2024",
+			want: []string{"go2024"},
+		},
+		{
+			name: "Underscores",
+			body: "#snake_case",
+			want: []string{"snake_case"},
+		},
+		{
+			name: "Inside a word",
+			body: "a#b issue#12",
+			want: nil,
+		},
+		{
+			name: "Double hash",
+			body: "##go",
+			want: []string{"go"},
+		},
+		{
+			name: "Non ASCII letters",
+			body: "#Café",
+			want: []string{"café"},
+		},
+		{
+			name: "Longest tag",
+			body: "#" + strings.Repeat("a", maxTagLength),
+			want: []string{strings.Repeat("a", maxTagLength)},
+		},
+		{
+			name: "Too long",
+			body: "#" + strings.Repeat("a", maxTagLength+1),
+			want: nil,
+		},
+		{
+			name: "Lone hash",
+			body: "# nothing here #",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractHashtags(tt.body); !slices.Equal(got, tt.want) {
+				t.Errorf("extractHashtags(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+type threadNode struct {
+	chirpResponse
+	ReplyCount int64         `json:"reply_count"`
+	Replies    []*threadNode `json:"replies"`
+}
+
+func (cfg *apiConfig) handlerGetChirpThread(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	rows, err := cfg.dbQueries.GetChirpThread(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting thread", err)
+		return
+	}
+	if len(rows) == 0 {
+		respondWithError(w, http.StatusNotFound, "Could not get chirp", nil)
+		return
+	}
+
+	chirps := make([]chirpResponse, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, newChirpResponse(row.Chirp))
+	}
+
+	err = cfg.decorateChirps(r.Context(), chirps, cfg.viewer(r))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting chirp details", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, buildThread(rows, chirps))
+}
+
+// buildThread turns the rows of a thread, ordered by creation time, into a
+// tree rooted at the first chirp of the conversation. chirps holds the
+// response for each row at the same index.
+func buildThread(rows []database.GetChirpThreadRow, chirps []chirpResponse) *threadNode {
+	nodes := make(map[uuid.UUID]*threadNode, len(rows))
+	var root *threadNode
+
+	for i, row := range rows {
+		node := &threadNode{
+			chirpResponse: chirps[i],
+			ReplyCount:    row.ReplyCount,
+			Replies:       []*threadNode{},
+		}
+		nodes[row.Chirp.ID] = node
+
+		if !row.Chirp.ThreadID.Valid {
+			root = node
+			continue
+		}
+		if parent, ok := nodes[row.Chirp.InReplyTo.UUID]; ok && row.Chirp.InReplyTo.Valid {
+			parent.Replies = append(parent.Replies, node)
+		} else if parent, ok := nodes[row.Chirp.ThreadID.UUID]; ok {
+			// The direct parent is gone, so hang the reply off the root.
+			parent.Replies = append(parent.Replies, node)
+		}
+	}
+
+	return root
+}
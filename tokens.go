@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/auth"
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	securityEventRefreshTokenReuse = "refresh_token_reuse"
+
+	maxDeviceNameLength = 100
+)
+
+var (
+	errInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	errRefreshTokenReused  = errors.New("refresh token was already used")
+)
+
+// sessionClient describes the device a session was last used from.
+type sessionClient struct {
+	deviceName string
+	userAgent  string
+	ipAddress  string
+}
+
+// newSessionClient captures the client of r. The device name is picked by
+// the user at login and truncated to maxDeviceNameLength characters.
+func newSessionClient(r *http.Request, deviceName string) sessionClient {
+	deviceName = strings.TrimSpace(deviceName)
+	if runes := []rune(deviceName); len(runes) > maxDeviceNameLength {
+		deviceName = string(runes[:maxDeviceNameLength])
+	}
+	return sessionClient{
+		deviceName: deviceName,
+		userAgent:  r.UserAgent(),
+		ipAddress:  clientIP(r),
+	}
+}
+
+// issueRefreshToken creates a refresh token in the given family and returns
+// it. Only its hash is stored.
+func issueRefreshToken(ctx context.Context, q *database.Queries, userID, familyID uuid.UUID, client sessionClient) (string, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		TokenHash:  auth.HashToken(token),
+		UserID:     userID,
+		FamilyID:   familyID,
+		ExpiresAt:  time.Now().AddDate(0, 0, refreshTokenTTLDays),
+		DeviceName: client.deviceName,
+		UserAgent:  client.userAgent,
+		IpAddress:  client.ipAddress,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// rotateRefreshToken exchanges a refresh token for a new one in the same
+// family. Each token works once: presenting a token that was rotated
+// already means it was copied, so the whole family is revoked and the
+// attempt is recorded as a security event. Banned and suspended users get
+// no new token.
+func (cfg *apiConfig) rotateRefreshToken(r *http.Request, token string) (database.RefreshToken, string, error) {
+	ctx := r.Context()
+	current, err := cfg.dbQueries.GetRefreshToken(ctx, auth.HashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.RefreshToken{}, "", errInvalidRefreshToken
+	}
+	if err != nil {
+		return database.RefreshToken{}, "", err
+	}
+	if current.RevokedAt.Valid || !current.ExpiresAt.After(time.Now()) {
+		return database.RefreshToken{}, "", errInvalidRefreshToken
+	}
+	if current.RotatedAt.Valid {
+		return current, "", cfg.revokeReusedFamily(r, current)
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.RefreshToken{}, "", err
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+	rotated, err := qtx.RotateRefreshToken(ctx, current.TokenHash)
+	if err != nil {
+		return database.RefreshToken{}, "", err
+	}
+	if rotated == 0 {
+		// A concurrent request rotated the token between the lookup and
+		// the update, which is reuse all the same.
+		tx.Rollback()
+		return current, "", cfg.revokeReusedFamily(r, current)
+	}
+
+	user, err := qtx.GetUserByID(ctx, current.UserID)
+	if err != nil {
+		return database.RefreshToken{}, "", err
+	}
+	err = checkStanding(user.Banned, user.SuspendedUntil)
+	if err != nil {
+		return database.RefreshToken{}, "", err
+	}
+
+	// The session keeps its name but moves along with the client.
+	next, err := issueRefreshToken(ctx, qtx, current.UserID, current.FamilyID, newSessionClient(r, current.DeviceName))
+	if err != nil {
+		return database.RefreshToken{}, "", err
+	}
+	return current, next, tx.Commit()
+}
+
+// revokeReusedFamily signs out the session a reused refresh token belongs
+// to and records the event. It returns errRefreshTokenReused unless the
+// revocation itself fails.
+func (cfg *apiConfig) revokeReusedFamily(r *http.Request, token database.RefreshToken) error {
+	err := cfg.dbQueries.RevokeRefreshTokenFamily(r.Context(), token.FamilyID)
+	if err != nil {
+		return err
+	}
+	cfg.recordSecurityEvent(r, token.UserID, securityEventRefreshTokenReuse,
+		fmt.Sprintf("token family %s revoked after a rotated refresh token was presented again", token.FamilyID))
+	return errRefreshTokenReused
+}
+
+// recordSecurityEvent stores and logs an event worth a user's or an
+// operator's attention. Failing to store it doesn't fail the request.
+func (cfg *apiConfig) recordSecurityEvent(r *http.Request, userID uuid.UUID, event, details string) {
+	log.Printf("Security event %s for user %s from %s: %s", event, userID, clientIP(r), details)
+	err := cfg.dbQueries.CreateSecurityEvent(r.Context(), database.CreateSecurityEventParams{
+		UserID:    userID,
+		Event:     event,
+		Details:   details,
+		IpAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+	})
+	if err != nil {
+		log.Printf("Error while storing security event: %v", err)
+	}
+}
+
+// handlerJWKS publishes the public keys access tokens are signed with, so
+// other services can verify them without sharing a secret.
+func (cfg *apiConfig) handlerJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, cfg.keys.JWKS())
+}
+
+// clientIP returns the address of the peer that sent r, without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
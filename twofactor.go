@@ -0,0 +1,551 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/auth"
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer = "Chirpy"
+
+	recoveryCodeCount     = 10
+	twoFactorChallengeTTL = 5 * time.Minute
+	maxTwoFactorAttempts  = 5
+
+	securityEventRecoveryCodeUsed      = "recovery_code_used"
+	securityEventRecoveryCodesReplaced = "recovery_codes_regenerated"
+	securityEventTwoFactorDisabled     = "two_factor_disabled"
+)
+
+var errIncorrectPassword = errors.New("password is incorrect")
+
+// confirmPassword returns the user if password is theirs. Changing how an
+// account signs in needs more than an access token.
+func (cfg *apiConfig) confirmPassword(ctx context.Context, userID uuid.UUID, password string) (database.User, error) {
+	user, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return database.User{}, err
+	}
+	err = auth.CheckPasswordHash(user.HashedPassword, password)
+	if err != nil {
+		return database.User{}, errIncorrectPassword
+	}
+	return user, nil
+}
+
+// replaceRecoveryCodes drops the user's recovery codes and returns a new
+// set. Only their hashes are stored.
+func replaceRecoveryCodes(ctx context.Context, q *database.Queries, userID uuid.UUID) ([]string, error) {
+	err := q.DeleteUserRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := auth.MakeRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		err = q.CreateRecoveryCode(ctx, database.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashRecoveryCode(code),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// handlerGetTwoFactor reports whether the signed in user has two-factor
+// authentication enabled.
+func (cfg *apiConfig) handlerGetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	type returnVals struct {
+		Enabled                bool  `json:"enabled"`
+		RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+	}
+
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	credential, err := cfg.dbQueries.GetTotpCredential(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !credential.EnabledAt.Valid) {
+		respondWithJSON(w, http.StatusOK, returnVals{})
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting two-factor authentication", err)
+		return
+	}
+
+	remaining, err := cfg.dbQueries.CountUnusedRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while counting recovery codes", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, returnVals{
+		Enabled:                true,
+		RecoveryCodesRemaining: remaining,
+	})
+}
+
+// handlerEnrollTwoFactor creates a TOTP secret for the signed in user. It
+// takes effect once a code generated from it is confirmed; enrolling again
+// before that replaces the secret.
+func (cfg *apiConfig) handlerEnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+
+	type returnVals struct {
+		Secret      string `json:"secret"`
+		Otpauth_uri string `json:"otpauth_uri"`
+	}
+
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.confirmPassword(r.Context(), userID, params.Password)
+	if errors.Is(err, errIncorrectPassword) {
+		respondWithError(w, http.StatusForbidden, err.Error(), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting user", err)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while creating secret", err)
+		return
+	}
+
+	// The upsert leaves an enabled credential alone and returns no row.
+	_, err = cfg.dbQueries.UpsertTotpCredential(r.Context(), database.UpsertTotpCredentialParams{
+		UserID: userID,
+		Secret: secret,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while storing secret", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, returnVals{
+		Secret:      secret,
+		Otpauth_uri: auth.TOTPURI(secret, totpIssuer, user.Email),
+	})
+}
+
+// handlerConfirmTwoFactor enables two-factor authentication with the first
+// code from the user's authenticator and hands out the recovery codes. They
+// are shown this one time only.
+func (cfg *apiConfig) handlerConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	type returnVals struct {
+		Recovery_codes []string `json:"recovery_codes"`
+	}
+
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	credential, err := cfg.dbQueries.GetTotpCredential(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "Start enrollment first", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting two-factor authentication", err)
+		return
+	}
+	if credential.EnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(credential.Secret, params.Code, time.Now())
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "incorrect code", nil)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while enabling two-factor authentication", err)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+	enabled, err := qtx.EnableTotpCredential(r.Context(), database.EnableTotpCredentialParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while enabling two-factor authentication", err)
+		return
+	}
+	if enabled == 0 {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(r.Context(), qtx, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while creating recovery codes", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while enabling two-factor authentication", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, returnVals{
+		Recovery_codes: codes,
+	})
+}
+
+// handlerRegenerateRecoveryCodes replaces the signed in user's recovery
+// codes, for when the old ones were lost or used up.
+func (cfg *apiConfig) handlerRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+
+	type returnVals struct {
+		Recovery_codes []string `json:"recovery_codes"`
+	}
+
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	_, err = cfg.confirmPassword(r.Context(), userID, params.Password)
+	if errors.Is(err, errIncorrectPassword) {
+		respondWithError(w, http.StatusForbidden, err.Error(), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting user", err)
+		return
+	}
+
+	credential, err := cfg.dbQueries.GetTotpCredential(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !credential.EnabledAt.Valid) {
+		respondWithError(w, http.StatusBadRequest, "Two-factor authentication is not enabled", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting two-factor authentication", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while creating recovery codes", err)
+		return
+	}
+	defer tx.Rollback()
+
+	codes, err := replaceRecoveryCodes(r.Context(), cfg.dbQueries.WithTx(tx), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while creating recovery codes", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while creating recovery codes", err)
+		return
+	}
+
+	cfg.recordSecurityEvent(r, userID, securityEventRecoveryCodesReplaced, "recovery codes were regenerated")
+	respondWithJSON(w, http.StatusOK, returnVals{
+		Recovery_codes: codes,
+	})
+}
+
+// handlerDisableTwoFactor turns two-factor authentication off and drops the
+// secret and recovery codes. Once it is enabled, turning it off takes a
+// current code or a recovery code besides the password, so a stolen
+// password and session can't remove the second factor.
+func (cfg *apiConfig) handlerDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	_, err = cfg.confirmPassword(r.Context(), userID, params.Password)
+	if errors.Is(err, errIncorrectPassword) {
+		respondWithError(w, http.StatusForbidden, err.Error(), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting user", err)
+		return
+	}
+
+	credential, err := cfg.dbQueries.GetTotpCredential(r.Context(), userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting two-factor authentication", err)
+		return
+	}
+	// A setup that was never confirmed can be dropped with the password.
+	if err == nil && credential.EnabledAt.Valid {
+		ok, err := cfg.verifySecondFactor(r, userID, params.Code, params.RecoveryCode)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error while checking code", err)
+			return
+		}
+		if !ok {
+			respondWithError(w, http.StatusForbidden, "incorrect code", nil)
+			return
+		}
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while disabling two-factor authentication", err)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+	err = qtx.DeleteTotpCredential(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while disabling two-factor authentication", err)
+		return
+	}
+	err = qtx.DeleteUserRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while disabling two-factor authentication", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while disabling two-factor authentication", err)
+		return
+	}
+
+	cfg.recordSecurityEvent(r, userID, securityEventTwoFactorDisabled, "two-factor authentication was disabled")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startTwoFactorChallenge answers a login with a correct password by asking
+// for the second factor. The challenge token stands in for the password in
+// POST /api/login/2fa.
+func (cfg *apiConfig) startTwoFactorChallenge(w http.ResponseWriter, r *http.Request, userID uuid.UUID, deviceName string) {
+	type returnVals struct {
+		TwoFactorRequired bool      `json:"two_factor_required"`
+		ChallengeToken    string    `json:"challenge_token"`
+		Expires_at        time.Time `json:"expires_at"`
+	}
+
+	// Nothing else cleans up challenges that were never answered.
+	err := cfg.dbQueries.DeleteExpiredTwoFactorChallenges(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while creating challenge", err)
+		return
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while creating challenge", err)
+		return
+	}
+	expiresAt := time.Now().Add(twoFactorChallengeTTL)
+	err = cfg.dbQueries.CreateTwoFactorChallenge(r.Context(), database.CreateTwoFactorChallengeParams{
+		TokenHash:  auth.HashToken(token),
+		UserID:     userID,
+		DeviceName: deviceName,
+		ExpiresAt:  expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while creating challenge", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, returnVals{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		Expires_at:        expiresAt,
+	})
+}
+
+// verifySecondFactor checks a TOTP code or, if one is given, a recovery
+// code. Either works only once.
+func (cfg *apiConfig) verifySecondFactor(r *http.Request, userID uuid.UUID, code, recoveryCode string) (bool, error) {
+	ctx := r.Context()
+	if recoveryCode != "" {
+		used, err := cfg.dbQueries.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashRecoveryCode(recoveryCode),
+		})
+		if err != nil || used == 0 {
+			return false, err
+		}
+
+		remaining, err := cfg.dbQueries.CountUnusedRecoveryCodes(ctx, userID)
+		if err != nil {
+			return false, err
+		}
+		cfg.recordSecurityEvent(r, userID, securityEventRecoveryCodeUsed,
+			fmt.Sprintf("a recovery code was used, %d left", remaining))
+		return true, nil
+	}
+
+	credential, err := cfg.dbQueries.GetTotpCredential(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := auth.ValidateTOTP(credential.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	used, err := cfg.dbQueries.UseTotpStep(ctx, database.UseTotpStepParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+	return used == 1, err
+}
+
+// handlerLoginTwoFactor finishes a login that was answered with a challenge
+// token. Each challenge allows a few attempts before the password has to be
+// entered again.
+func (cfg *apiConfig) handlerLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	challenge, err := cfg.dbQueries.ClaimTwoFactorAttempt(r.Context(), database.ClaimTwoFactorAttemptParams{
+		TokenHash: auth.HashToken(params.ChallengeToken),
+		Attempts:  maxTwoFactorAttempts,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge, log in again", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while checking challenge", err)
+		return
+	}
+
+	ok, err := cfg.verifySecondFactor(r, challenge.UserID, params.Code, params.RecoveryCode)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while checking code", err)
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "incorrect code", nil)
+		return
+	}
+
+	// Two requests with valid codes could race; only one gets the tokens.
+	deleted, err := cfg.dbQueries.DeleteTwoFactorChallenge(r.Context(), challenge.TokenHash)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while checking challenge", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge, log in again", nil)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), challenge.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting user", err)
+		return
+	}
+
+	err = checkStanding(user.Banned, user.SuspendedUntil)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	cfg.completeLogin(w, r, user, challenge.DeviceName)
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
@@ -10,10 +13,17 @@ import (
 	"github.com/google/uuid"
 )
 
+// Unique constraints on users, named the way Postgres names them by default.
+const (
+	usersEmailConstraint  = "users_email_key"
+	usersHandleConstraint = "users_handle_key"
+)
+
 func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Handle   string `json:"handle"`
 	}
 
 	type returnVals struct {
@@ -21,7 +31,11 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 		Created_at  time.Time `json:"created_at"`
 		Updated_at  time.Time `json:"updated_at"`
 		Email       string    `json:"email"`
+		Handle      string    `json:"handle,omitempty"`
 		IsChirpyRed bool      `json:"is_chirpy_red"`
+		// Email_verified stays false until the link mailed at signup is
+		// opened.
+		Email_verified bool `json:"email_verified"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -32,12 +46,24 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	params.Email, err = parseEmailParam(params.Email)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
 	_, err = cfg.dbQueries.GetUser(r.Context(), params.Email)
 	if err == nil {
 		respondWithError(w, http.StatusBadRequest, "Email already registered, can not create user", err)
 		return
 	}
 
+	handle, err := parseHandleParam(params.Handle)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "can't use password: %v", err)
@@ -47,18 +73,35 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 	user, err := cfg.dbQueries.CreateUser(r.Context(), database.CreateUserParams{
 		Email:          params.Email,
 		HashedPassword: hashedPassword,
+		Handle:         handle,
 	})
+	if isUniqueViolationOf(err, usersEmailConstraint) {
+		respondWithError(w, http.StatusConflict, "Email already taken", err)
+		return
+	}
+	if isUniqueViolationOf(err, usersHandleConstraint) {
+		respondWithError(w, http.StatusConflict, "Handle already taken", err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Error while creating user: %s", err)
 		return
 	}
 
+	// The account exists either way; a failed email can be resent.
+	err = cfg.sendEmailVerification(r.Context(), user.ID, user.Email)
+	if err != nil {
+		log.Printf("Error while sending verification email: %v", err)
+	}
+
 	response := returnVals{
-		Id:          user.ID,
-		Created_at:  user.CreatedAt,
-		Updated_at:  user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed.Bool,
+		Id:             user.ID,
+		Created_at:     user.CreatedAt,
+		Updated_at:     user.UpdatedAt,
+		Email:          user.Email,
+		Handle:         user.Handle.String,
+		IsChirpyRed:    user.IsChirpyRed.Bool,
+		Email_verified: user.EmailVerifiedAt.Valid,
 	}
 
 	respondWithJSON(w, http.StatusCreated, response)
@@ -69,6 +112,8 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		// DeviceName labels the session in GET /api/sessions.
+		DeviceName string `json:"device_name"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -91,36 +136,66 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Access Token
-	expirationTime := time.Hour
-
-	token, err := auth.MakeJWT(user.ID, cfg.tokenSecret, expirationTime)
+	err = checkStanding(user.Banned, user.SuspendedUntil)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error while creating access token", err)
+		respondWithAuthError(w, err)
 		return
 	}
 
-	// Refresh Token
-	refreshTokenString, _ := auth.MakeRefreshToken()
-	refreshTokenExpiration := time.Now().AddDate(0, 0, 60)
+	// With two-factor authentication the tokens are only issued by
+	// POST /api/login/2fa.
+	credential, err := cfg.dbQueries.GetTotpCredential(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "Error while checking two-factor authentication", err)
+		return
+	}
+	if err == nil && credential.EnabledAt.Valid {
+		cfg.startTwoFactorChallenge(w, r, user.ID, params.DeviceName)
+		return
+	}
 
-	refreshToken, err := cfg.dbQueries.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		Token:     refreshTokenString,
-		UserID:    user.ID,
-		ExpiresAt: refreshTokenExpiration,
-	})
+	cfg.completeLogin(w, r, user, params.DeviceName)
+}
+
+// completeLogin signs a user in whose credentials were checked and starts a
+// new session for them.
+func (cfg *apiConfig) completeLogin(w http.ResponseWriter, r *http.Request, user database.User, deviceName string) {
+	// Logging in during the grace period keeps the account.
+	if user.DeletionRequestedAt.Valid {
+		err := cfg.dbQueries.CancelUserDeletion(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error while restoring account", err)
+			return
+		}
+	}
+
+	// Refresh Token, starting a new family that is the session
+	sessionID := uuid.New()
+	refreshToken, err := issueRefreshToken(r.Context(), &cfg.dbQueries, user.ID, sessionID, newSessionClient(r, deviceName))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error while storing refresh token: %v", err)
 		return
 	}
 
+	// Access Token
+	expirationTime := time.Hour
+
+	token, err := cfg.keys.MakeSessionJWT(user.ID, sessionID, expirationTime, user.Roles...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while creating access token", err)
+		return
+	}
+
 	// JSON Response
 	type returnVals struct {
 		Id            uuid.UUID `json:"id"`
 		Created_at    time.Time `json:"created_at"`
 		Updated_at    time.Time `json:"updated_at"`
 		Email         string    `json:"email"`
+		Handle        string    `json:"handle,omitempty"`
 		IsChirpyRed   bool      `json:"is_chirpy_red"`
+		EmailVerified bool      `json:"email_verified"`
+		Roles         []string  `json:"roles"`
 		Token         string    `json:"token"`
 		Refresh_token string    `json:"refresh_token"`
 	}
@@ -130,11 +205,17 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		Created_at:    user.CreatedAt,
 		Updated_at:    user.UpdatedAt,
 		Email:         user.Email,
+		Handle:        user.Handle.String,
 		IsChirpyRed:   user.IsChirpyRed.Bool,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Roles:         user.Roles,
 		Token:         token,
-		Refresh_token: refreshToken.Token,
+		Refresh_token: refreshToken,
 	})
 }
+
+// handlerRefresh trades a refresh token for a new access token and a new
+// refresh token. The presented refresh token stops working.
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 	refreshTokenString, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -142,32 +223,40 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	refreshToken, err := cfg.dbQueries.GetRefreshToken(r.Context(), refreshTokenString)
-	if err != nil {
+	current, next, err := cfg.rotateRefreshToken(r, refreshTokenString)
+	if errors.Is(err, errInvalidRefreshToken) || errors.Is(err, errRefreshTokenReused) {
 		respondWithError(w, http.StatusUnauthorized, "Wrong / Invalid refresh token in header", err)
 		return
 	}
+	if errors.Is(err, errAccountBanned) || errors.Is(err, errAccountSuspended) {
+		respondWithAuthError(w, err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while rotating refresh token", err)
+		return
+	}
 
-	user, err := cfg.dbQueries.GetUserFromRefreshToken(r.Context(), database.GetUserFromRefreshTokenParams{
-		Token:     refreshToken.Token,
-		ExpiresAt: time.Now(),
-	})
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), current.UserID)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Error while fetching user via refresh token", err)
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(user.ID, cfg.tokenSecret, time.Hour)
+	accessToken, err := cfg.keys.MakeSessionJWT(user.ID, current.FamilyID, time.Hour, user.Roles...)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Error while creating access token", err)
+		return
 	}
 
 	type returnVals struct {
-		Token string `json:"token"`
+		Token         string `json:"token"`
+		Refresh_token string `json:"refresh_token"`
 	}
 
 	respondWithJSON(w, http.StatusOK, returnVals{
-		Token: accessToken,
+		Token:         accessToken,
+		Refresh_token: next,
 	})
 }
 
@@ -178,9 +267,17 @@ func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = cfg.dbQueries.RevokeRefreshToken(r.Context(), refreshTokenString)
+	// Signing out ends the whole session, not just its latest token.
+	refreshToken, err := cfg.dbQueries.GetRefreshToken(r.Context(), auth.HashToken(refreshTokenString))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Error while revoking refresh token", err)
+		return
+	}
+
+	err = cfg.dbQueries.RevokeRefreshTokenFamily(r.Context(), refreshToken.FamilyID)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Error while revoking refresh token", err)
+		return
 	}
 
 	w.WriteHeader(204)
@@ -190,16 +287,12 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 	type parameters struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Handle   string `json:"handle"`
 	}
 
-	accessToken, err := auth.GetBearerToken(r.Header)
+	userID, err := cfg.authenticate(r)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Malformed or missing access token", err)
-	}
-
-	userID, err := auth.ValidateJWT(accessToken, cfg.tokenSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Malformed or missing access token", err)
+		respondWithAuthError(w, err)
 		return
 	}
 
@@ -211,6 +304,18 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	params.Email, err = parseEmailParam(params.Email)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	handle, err := parseHandleParam(params.Handle)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error while hashing password", err)
@@ -220,8 +325,13 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 	user, err := cfg.dbQueries.UpdateUser(r.Context(), database.UpdateUserParams{
 		Email:          params.Email,
 		HashedPassword: hashedPassword,
+		Handle:         handle,
 		ID:             userID,
 	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email or handle already taken", err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error while updating user", err)
 		return
@@ -232,6 +342,7 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 		Created_at  time.Time `json:"created_at"`
 		Updated_at  time.Time `json:"updated_at"`
 		Email       string    `json:"email"`
+		Handle      string    `json:"handle,omitempty"`
 		IsChirpyRed bool      `json:"is_chirpy_red"`
 	}
 
@@ -240,6 +351,7 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 		Created_at:  user.CreatedAt,
 		Updated_at:  user.UpdatedAt,
 		Email:       user.Email,
+		Handle:      user.Handle.String,
 		IsChirpyRed: user.IsChirpyRed.Bool,
 	})
 }
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/auth"
+	"github.com/alexanderarrr/chirpy-http-server/internal/database"
+	"github.com/alexanderarrr/chirpy-http-server/internal/mail"
+	"github.com/google/uuid"
+)
+
+const (
+	maxEmailLength       = 254
+	emailVerificationTTL = 48 * time.Hour
+	// A verification email can be resent once a minute, and at most
+	// maxVerificationsPerDay times a day.
+	verificationResendInterval = time.Minute
+	maxVerificationsPerDay     = 5
+)
+
+var errEmailNotVerified = errors.New("email address is not verified")
+
+// parseEmailParam checks that email is a bare address such as
+// "user@example.com", without a display name or comments.
+func parseEmailParam(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	invalid := fmt.Errorf("%q is not a valid email address", email)
+	if email == "" || len(email) > maxEmailLength {
+		return "", invalid
+	}
+
+	address, err := netmail.ParseAddress(email)
+	if err != nil || address.Name != "" || address.Address != email {
+		return "", invalid
+	}
+	_, domain, _ := strings.Cut(email, "@")
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "", invalid
+	}
+	return email, nil
+}
+
+// sendEmailVerification mails a link that verifies the user's address.
+func (cfg *apiConfig) sendEmailVerification(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+	err = cfg.dbQueries.CreateEmailVerification(ctx, database.CreateEmailVerificationParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().Add(emailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.mailer.Send(ctx, emailVerificationMessage(cfg.publicURL, email, token))
+}
+
+// emailVerificationMessage is the email carrying the verification link for
+// token.
+func emailVerificationMessage(publicURL, email, token string) mail.Message {
+	link := strings.TrimSuffix(publicURL, "/") + "/api/users/verify?token=" + url.QueryEscape(token)
+	return mail.Message{
+		To:      email,
+		Subject: "Verify your Chirpy email",
+		Body: fmt.Sprintf("Welcome to Chirpy! Open this link to verify your email address:\n\n%s\n\n"+
+			"It expires in %d hours.", link, int(emailVerificationTTL.Hours())),
+	}
+}
+
+// checkCanPost enforces REQUIRE_VERIFIED_EMAIL for endpoints that publish
+// content.
+func (cfg *apiConfig) checkCanPost(ctx context.Context, userID uuid.UUID) error {
+	if !cfg.requireVerifiedEmail {
+		return nil
+	}
+	user, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.EmailVerifiedAt.Valid {
+		return errEmailNotVerified
+	}
+	return nil
+}
+
+// handlerVerifyEmail marks the address a verification token was sent to as
+// verified. GET serves the link from the email; POST takes the token in
+// the body.
+func (cfg *apiConfig) handlerVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+
+	type returnVals struct {
+		Id              uuid.UUID `json:"id"`
+		Email           string    `json:"email"`
+		Email_verified  bool      `json:"email_verified"`
+		EmailVerifiedAt time.Time `json:"email_verified_at"`
+	}
+
+	params := parameters{Token: r.URL.Query().Get("token")}
+	if r.Method == http.MethodPost {
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&params)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+			return
+		}
+	}
+
+	verification, err := cfg.dbQueries.GetEmailVerification(r.Context(), auth.HashToken(params.Token))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while verifying email", err)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+	// Tokens sent to an address the account no longer uses don't match.
+	user, err := qtx.MarkUserEmailVerified(r.Context(), database.MarkUserEmailVerifiedParams{
+		ID:    verification.UserID,
+		Email: verification.Email,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while verifying email", err)
+		return
+	}
+
+	err = qtx.DeleteUserEmailVerifications(r.Context(), verification.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while verifying email", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while verifying email", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, returnVals{
+		Id:              user.ID,
+		Email:           user.Email,
+		Email_verified:  true,
+		EmailVerifiedAt: user.EmailVerifiedAt.Time,
+	})
+}
+
+// handlerResendVerification sends a new verification email to the signed
+// in user, rate limited per account.
+func (cfg *apiConfig) handlerResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while getting user", err)
+		return
+	}
+	if user.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Email is already verified", nil)
+		return
+	}
+
+	limits := []struct {
+		window time.Duration
+		max    int64
+	}{
+		{verificationResendInterval, 1},
+		{24 * time.Hour, maxVerificationsPerDay},
+	}
+	for _, limit := range limits {
+		count, err := cfg.dbQueries.CountEmailVerificationsSince(r.Context(), database.CountEmailVerificationsSinceParams{
+			UserID:    userID,
+			CreatedAt: time.Now().Add(-limit.window),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error while checking rate limit", err)
+			return
+		}
+		if count >= limit.max {
+			w.Header().Set("Retry-After", strconv.Itoa(int(limit.window.Seconds())))
+			respondWithError(w, http.StatusTooManyRequests, "Too many verification emails, try again later", nil)
+			return
+		}
+	}
+
+	err = cfg.sendEmailVerification(r.Context(), user.ID, user.Email)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error while sending verification email", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexanderarrr/chirpy-http-server/internal/mail"
+)
+
+func TestVerificationLinksAreNotServed(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"index.html": "<html></html>"})
+	handler := http.StripPrefix("/app", appFileServer(root))
+
+	// Even an outbox inside the served tree, where it used to be kept by
+	// default, must not leak the links.
+	outbox := filepath.Join(root, "outbox")
+	mailer := &mail.OutboxMailer{Dir: outbox, From: "Chirpy <no-reply@localhost>"}
+	const token = "verification-token"
+	err := mailer.Send(context.Background(), emailVerificationMessage("http://localhost:8080", "user@example.com", token))
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(outbox)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("outbox has %d messages, error = %v, want 1", len(entries), err)
+	}
+	data, _ := os.ReadFile(filepath.Join(outbox, entries[0].Name()))
+	if !strings.Contains(string(data), token) {
+		t.Fatal("message doesn't contain the verification link")
+	}
+
+	for _, path := range []string{"/app/outbox/", "/app/outbox/" + entries[0].Name()} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), token) {
+			t.Errorf("GET %s = %d, want %d without the token", path, w.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestNewMailer(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheDir)
+	t.Setenv("HOME", cacheDir)
+	t.Setenv("MAIL_OUTBOX_DIR", "")
+
+	t.Setenv("MAIL_DELIVERY", "")
+	if _, err := newMailer(); err == nil {
+		t.Error("newMailer() fell back to a default delivery")
+	}
+
+	t.Setenv("MAIL_DELIVERY", "outbox")
+	mailer, err := newMailer()
+	if err != nil {
+		t.Fatalf("newMailer() error = %v", err)
+	}
+	outbox, ok := mailer.(*mail.OutboxMailer)
+	if !ok {
+		t.Fatalf("newMailer() = %T, want an outbox", mailer)
+	}
+	wd, _ := os.Getwd()
+	if rel, err := filepath.Rel(wd, outbox.Dir); err == nil && !strings.HasPrefix(rel, "..") {
+		t.Errorf("outbox %s is inside the served directory %s", outbox.Dir, wd)
+	}
+}